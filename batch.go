@@ -0,0 +1,393 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cornfeedhobo/ssh-keydgen/keygen"
+	"github.com/cornfeedhobo/ssh-keydgen/secret"
+	"github.com/cornfeedhobo/ssh-keydgen/slowseeder"
+	"github.com/mitchellh/go-homedir"
+	"gopkg.in/urfave/cli.v1"
+	"gopkg.in/yaml.v2"
+)
+
+// manifestEntry describes one key of a batch manifest, falling back to
+// the global flags for anything it leaves out
+type manifestEntry struct {
+	Label   string `json:"label" yaml:"label"`
+	Type    string `json:"type" yaml:"type"`
+	Bits    int    `json:"bits" yaml:"bits"`
+	Curve   int    `json:"curve" yaml:"curve"`
+	File    string `json:"file" yaml:"file"`
+	Comment string `json:"comment" yaml:"comment"`
+}
+
+// derivation holds the derivation parameters shared by every key of a batch
+type derivation struct {
+	seed                 []byte
+	rounds, time, memory uint32
+	threads              uint8
+}
+
+func batchAction(ctx *cli.Context) error {
+
+	parent := ctx.Parent()
+
+	if ctx.NArg() != 1 {
+		return newErrorKind(kindUsage, "A manifest file is required")
+	}
+
+	jobs := ctx.Int("jobs")
+	if jobs < 1 {
+		return newErrorKind(kindUsage, "At least one job is required")
+	}
+
+	if parent.Bool("force") && parent.Bool("no-overwrite") {
+		return newErrorKind(kindUsage, "Only one of --force, --no-overwrite can be used")
+	}
+
+	if err := lockMemory(parent); err != nil {
+		return err
+	}
+
+	if err := applyProfile(parent); err != nil {
+		return err
+	}
+
+	parent.Set("t", strings.ToLower(parent.String("t")))
+
+	if _, err := seedphraseForm(parent); err != nil {
+		return err
+	}
+
+	format, err := newKeyFormat(parent)
+	if err != nil {
+		return err
+	}
+	defer format.wipe()
+
+	if format.pgpSubkey {
+		return newErrorKind(kindUsage, "OpenPGP encryption subkeys are not supported by batch")
+	}
+
+	entries, err := readManifest(parent, ctx.Args().First(), format)
+	if err != nil {
+		return err
+	}
+
+	if ctx.String("jwks") != "" {
+		for _, entry := range entries {
+			if entry.Type == keygen.DSA || !keygen.SSHKeyType(entry.Type) {
+				return newErrorKind(kindUsage, "Key type "+entry.Type+" can not be written to a JSON Web Key Set: "+entry.Label)
+			}
+		}
+	}
+
+	if jobs > len(entries) {
+		jobs = len(entries)
+	}
+
+	fmt.Fprintf(stdout, "Generating %d keys, %d at a time\n", len(entries), jobs)
+
+	seed, err := getSeedphrase(parent)
+	if err != nil {
+		return err
+	}
+	defer func() { secret.Wipe(seed) }()
+
+	if parent.String("keyfile") != "" {
+		var mixed []byte
+		if mixed, err = mixKeyfile(seed, parent.String("keyfile")); err != nil {
+			return err
+		}
+		secret.Wipe(seed)
+		seed = mixed
+	}
+
+	d := &derivation{
+		seed:    seed,
+		rounds:  uint32(parent.Int("a")),
+		time:    uint32(parent.Uint("at")),
+		memory:  uint32(parent.Uint("am")),
+		threads: uint8(parent.Uint("ap")),
+	}
+
+	keys, err := generateBatch(d, entries, jobs)
+	if err != nil {
+		return err
+	}
+	defer wipeKeys(keys)
+
+	// generation can take long enough for files to appear meanwhile
+	if err = checkManifestFiles(parent, entries); err != nil {
+		return err
+	}
+
+	// keys are only written once all of them were generated
+	var (
+		report = bytes.NewBuffer(nil)
+		jwks   = &keygen.JWKSet{}
+	)
+	for i, entry := range entries {
+
+		if err = writeKeyToFile(keys[i], entry.File, format); err != nil {
+			return err
+		}
+
+		var fingerprint string
+		if fingerprint, err = keys[i].Fingerprint(); err != nil {
+			return newError(err.Error())
+		}
+
+		fmt.Fprintf(report, "%s %s %s\n", fingerprint, entry.Label, entry.File)
+
+		if ctx.String("jwks") != "" {
+			var j *keygen.JWK
+			if j, err = keys[i].JWK(); err != nil {
+				return newError(err.Error())
+			}
+			j.Wipe()
+			jwks.Keys = append(jwks.Keys, j.Public())
+		}
+
+		if err = recordKey(parent, keys[i], entry.Label, []string{entry.File, entry.File + ".pub"}, false); err != nil {
+			return err
+		}
+
+	}
+
+	fmt.Fprint(stdout, "\n"+report.String())
+
+	if filename := ctx.String("report"); filename != "" {
+		if err = replaceFile(filename, report.Bytes(), 0644); err != nil {
+			return newErrorKind(kindIO, "Error writing report: "+err.Error())
+		}
+	}
+
+	if filename := ctx.String("jwks"); filename != "" {
+		b, err := json.MarshalIndent(jwks, "", "  ")
+		if err != nil {
+			return newBug(err.Error())
+		}
+		if err = replaceFile(filename, append(b, '\n'), 0644); err != nil {
+			return newErrorKind(kindIO, "Error writing JSON Web Key Set: "+err.Error())
+		}
+	}
+
+	return nil
+
+}
+
+// readManifest reads and checks the manifest, filling in what entries
+// leave out from the global flags
+func readManifest(ctx *cli.Context, filename string, format *keyFormat) ([]manifestEntry, error) {
+
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, newErrorKind(kindIO, "Error reading manifest: "+err.Error())
+	}
+
+	var entries []manifestEntry
+	if err = decodeManifest(filename, b, &entries); err != nil {
+		return nil, newErrorKind(kindUsage, "Error reading manifest: "+err.Error())
+	}
+
+	if len(entries) == 0 {
+		return nil, newErrorKind(kindUsage, "Manifest lists no keys")
+	}
+
+	var (
+		labels = map[string]bool{}
+		files  = map[string]bool{}
+	)
+
+	for i := range entries {
+
+		entry := &entries[i]
+		where := "Manifest entry " + strconv.Itoa(i+1)
+
+		if entry.Label == "" {
+			return nil, newErrorKind(kindUsage, where+" has no label")
+		}
+		if labels[entry.Label] {
+			return nil, newErrorKind(kindUsage, where+" repeats the label "+entry.Label+", which would derive the same key")
+		}
+		labels[entry.Label] = true
+
+		if entry.File == "" {
+			return nil, newErrorKind(kindUsage, where+" has no file")
+		}
+		if entry.File, err = homedir.Expand(entry.File); err != nil {
+			return nil, newErrorKind(kindUsage, where+": "+err.Error())
+		}
+		if entry.File, err = filepath.Abs(entry.File); err != nil {
+			return nil, newErrorKind(kindUsage, where+": "+err.Error())
+		}
+		if files[entry.File] {
+			return nil, newErrorKind(kindUsage, where+" repeats the file "+entry.File)
+		}
+		files[entry.File] = true
+
+		if entry.Type == "" {
+			entry.Type = ctx.String("t")
+		}
+		entry.Type = strings.ToLower(entry.Type)
+		if entry.Bits == 0 {
+			entry.Bits = ctx.Int("b")
+		}
+		if entry.Curve == 0 {
+			entry.Curve = ctx.Int("c")
+		}
+		if entry.Comment == "" {
+			entry.Comment = ctx.String("C")
+		}
+
+		if err = format.check(entry.Type); err != nil {
+			return nil, err
+		}
+
+	}
+
+	if err = checkManifestFiles(ctx, entries); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+
+}
+
+// decodeManifest decodes a YAML manifest when filename ends in .yaml or
+// .yml, and a JSON one otherwise
+func decodeManifest(filename string, b []byte, v interface{}) error {
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		return yaml.Unmarshal(b, v)
+	}
+
+	return json.Unmarshal(b, v)
+
+}
+
+// checkManifestFiles fails when the key files of entries are symlinks, or
+// already exist without --force
+func checkManifestFiles(ctx *cli.Context, entries []manifestEntry) error {
+
+	for _, entry := range entries {
+		for _, name := range []string{entry.File, entry.File + ".pub"} {
+
+			if err := checkNotSymlink(name); err != nil {
+				return err
+			}
+
+			if _, err := os.Stat(name); err != nil || ctx.Bool("force") {
+				continue
+			}
+
+			if ctx.Bool("no-overwrite") {
+				return newErrorKind(kindExists, name+" already exists.")
+			}
+			return newErrorKind(kindExists, name+" already exists, use --force to overwrite it")
+
+		}
+	}
+
+	return nil
+
+}
+
+// generateBatch generates the keys of entries with a pool of workers,
+// returning them in manifest order regardless of which finished first
+func generateBatch(d *derivation, entries []manifestEntry, jobs int) ([]*keygen.Keydgen, error) {
+
+	ctx, cancel := interruptContext()
+	defer cancel()
+
+	var (
+		keys    = make([]*keygen.Keydgen, len(entries))
+		errs    = make([]error, len(entries))
+		indexes = make(chan int)
+		workers sync.WaitGroup
+	)
+
+	for w := 0; w < jobs; w++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for i := range indexes {
+				if keys[i], errs[i] = generateEntry(ctx, d, entries[i]); errs[i] != nil {
+					cancel()
+				}
+			}
+		}()
+	}
+
+	for i := range entries {
+		indexes <- i
+	}
+	close(indexes)
+	workers.Wait()
+
+	var interrupted bool
+	for i, err := range errs {
+		if err == context.Canceled {
+			interrupted = true
+		} else if err != nil {
+			wipeKeys(keys)
+			return nil, newError("Error generating key " + entries[i].Label + ": " + err.Error())
+		}
+	}
+
+	if interrupted {
+		wipeKeys(keys)
+		return nil, newErrorKind(kindInterrupted, "Key generation interrupted, no files were written")
+	}
+
+	return keys, nil
+
+}
+
+// generateEntry derives the key of a single manifest entry, salted with its label
+func generateEntry(ctx context.Context, d *derivation, entry manifestEntry) (*keygen.Keydgen, error) {
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	seeder, err := slowseeder.NewWithLabel(d.seed, []byte(derivationLabel(entry.Label, entry.Type)), d.rounds, d.time, d.memory, d.threads)
+	if err != nil {
+		return nil, err
+	}
+	defer seeder.Close()
+
+	k := &keygen.Keydgen{
+		Type:    entry.Type,
+		Bits:    uint16(entry.Bits),
+		Curve:   uint16(entry.Curve),
+		Comment: entry.Comment,
+	}
+
+	if _, err = k.GenerateKeyContext(ctx, seeder); err != nil {
+		return nil, err
+	}
+
+	return k, nil
+
+}
+
+func wipeKeys(keys []*keygen.Keydgen) {
+	for _, k := range keys {
+		if k != nil {
+			k.Wipe()
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cornfeedhobo/ssh-keydgen/keygen"
+	"github.com/cornfeedhobo/ssh-keydgen/slowseeder"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// testContext returns a context with the global flags of the app, as
+// parsed from args
+func testContext(args ...string) *cli.Context {
+
+	app := newApp()
+
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	for _, f := range app.Flags {
+		f.Apply(set)
+	}
+	set.Parse(args)
+
+	return cli.NewContext(app, set, nil)
+
+}
+
+func TestReadManifest(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "ssh-keydgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	manifests := map[string]string{
+		"hosts.json": `[
+  {"label": "web-1", "type": "ED25519", "file": "web-1", "comment": "deploy@web-1"},
+  {"label": "db-1", "bits": 4096, "file": "db-1"}
+]`,
+		"hosts.yaml": `- label: web-1
+  type: ED25519
+  file: web-1
+  comment: deploy@web-1
+- label: db-1
+  bits: 4096
+  file: db-1
+`,
+	}
+
+	for name, manifest := range manifests {
+
+		filename := filepath.Join(dir, name)
+		if err = ioutil.WriteFile(filename, []byte(manifest), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		// files are relative to the working directory, not the manifest
+		entries, err := readManifest(testContext("-C", "me@example.com"), filename, &keyFormat{name: formatSSH})
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		web, err := filepath.Abs("web-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		db, err := filepath.Abs("db-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []manifestEntry{
+			{Label: "web-1", Type: keygen.ED25519, Bits: 2048, Curve: 256, File: web, Comment: "deploy@web-1"},
+			{Label: "db-1", Type: keygen.RSA, Bits: 4096, Curve: 256, File: db, Comment: "me@example.com"},
+		}
+		if len(entries) != len(expected) {
+			t.Fatalf("%s: expected %d entries, got %+v", name, len(expected), entries)
+		}
+		for i := range expected {
+			if entries[i] != expected[i] {
+				t.Errorf("%s: expected %+v, got %+v", name, expected[i], entries[i])
+			}
+		}
+
+	}
+
+}
+
+func TestReadManifestErrors(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "ssh-keydgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	existing := filepath.Join(dir, "existing")
+	if err = ioutil.WriteFile(existing+".pub", []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		manifest string
+		args     []string
+		ok       bool
+	}{
+		{`[]`, nil, false},
+		{`[{"file": "a"}]`, nil, false},
+		{`[{"label": "a"}]`, nil, false},
+		{`[{"label": "a", "file": "a"}, {"label": "a", "file": "b"}]`, nil, false},
+		{`[{"label": "a", "file": "a"}, {"label": "b", "file": "a"}]`, nil, false},
+		{`[{"label": "a", "file": "` + existing + `"}]`, nil, false},
+		{`[{"label": "a", "file": "` + existing + `"}]`, []string{"-no-overwrite"}, false},
+		{`[{"label": "a", "file": "` + existing + `"}]`, []string{"-force"}, true},
+	}
+
+	filename := filepath.Join(dir, "manifest.json")
+	for _, c := range cases {
+
+		if err = ioutil.WriteFile(filename, []byte(c.manifest), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = readManifest(testContext(c.args...), filename, &keyFormat{name: formatSSH})
+		if (err == nil) != c.ok {
+			t.Errorf("%s %v: expected ok %v, got %v", c.manifest, c.args, c.ok, err)
+		}
+
+	}
+
+}
+
+func TestGenerateBatch(t *testing.T) {
+
+	// use small parameters to keep tests short
+	d := &derivation{seed: []byte("keygen"), rounds: 1, time: 1, memory: 512, threads: 1}
+
+	entries := []manifestEntry{
+		{Label: "web-1", Type: keygen.ED25519},
+		{Label: "web-2", Type: keygen.ED25519},
+		{Label: "db-1", Type: keygen.ED25519},
+	}
+
+	keys, err := generateBatch(d, entries, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wipeKeys(keys)
+
+	// each key is the one its label derives alone, whichever job made it
+	seen := map[string]bool{}
+	for i, entry := range entries {
+
+		k, err := generateEntry(context.Background(), d, entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer k.Wipe()
+
+		expected, err := k.MarshalPublicKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual, err := keys[i].MarshalPublicKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(expected, actual) {
+			t.Errorf("%s: expected %s, got %s", entry.Label, expected, actual)
+		}
+		if seen[string(actual)] {
+			t.Errorf("%s: repeats the key of another label", entry.Label)
+		}
+		seen[string(actual)] = true
+
+	}
+
+}
+
+func TestGenerateEntryLabel(t *testing.T) {
+
+	d := &derivation{seed: []byte("keygen"), rounds: 1, time: 1, memory: 512, threads: 1}
+
+	cases := []struct {
+		keyType, label string
+	}{
+		{keygen.ED25519, "x"},
+		{keygen.AGE, "x/age"},
+		{keygen.WIREGUARD, "x/wireguard"},
+	}
+
+	for _, c := range cases {
+
+		k, err := generateEntry(context.Background(), d, manifestEntry{Label: "x", Type: c.keyType})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer k.Wipe()
+
+		seeder, err := slowseeder.NewWithLabel(d.seed, []byte(c.label), d.rounds, d.time, d.memory, d.threads)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := &keygen.Keydgen{Type: c.keyType}
+		_, err = expected.GenerateKey(seeder)
+		seeder.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer expected.Wipe()
+
+		a, err := k.MarshalPublicKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := expected.MarshalPublicKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(a, b) {
+			t.Errorf("%s: expected the key derived under the label %q", c.keyType, c.label)
+		}
+
+	}
+
+}
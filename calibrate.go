@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/cornfeedhobo/ssh-keydgen/slowseeder"
+	"gopkg.in/urfave/cli.v1"
+)
+
+const (
+	// minimum Argon2 memory in KiB per thread
+	minMemoryPerThread = 8
+
+	// PBKDF2 rounds used to measure the cost of a single round
+	sampleRounds = 10000
+
+	// Argon2 memory in KiB used to measure the cost of a single KiB
+	sampleMemory = 8192
+)
+
+func calibrateAction(ctx *cli.Context) error {
+
+	var (
+		target  = ctx.Duration("target")
+		memory  = uint32(ctx.Uint("max-memory"))
+		threads = runtime.NumCPU()
+	)
+
+	if threads > 4 {
+		threads = 4
+	}
+
+	if target <= 0 {
+		return newErrorKind(kindUsage, "Target duration must be greater than zero")
+	}
+
+	if memory < minMemoryPerThread*uint32(threads) {
+		return newErrorKind(kindUsage, fmt.Sprintf("Memory budget must be at least %d KiB", minMemoryPerThread*threads))
+	}
+
+	fmt.Fprintf(stdout, "Calibrating key derivation to take %s on this machine...\n", target)
+
+	p, err := calibrate(target, memory, uint8(threads), measureDerivation)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "\nMeasured %s per derivation with these parameters:\n\n", p.measured.Round(10*time.Millisecond))
+	fmt.Fprintf(stdout, "  -a %d --at %d --am %d --ap %d\n\n", p.rounds, p.passes, p.memory, threads)
+	fmt.Fprintf(stdout, "[%s]\nrounds = %d\ntime = %d\nmemory = %d\nthreads = %d\n\n", ctx.String("name"), p.rounds, p.passes, p.memory, threads)
+	fmt.Fprintln(stdout, "ed25519 and ecdsa keys need only a few derivations, while rsa and dsa")
+	fmt.Fprintln(stdout, "keys need hundreds, so consider a shorter target for those.")
+
+	if res := currentResult(ctx); res != nil {
+		res.Calibration = &derivationResult{Rounds: int(p.rounds), Time: uint(p.passes), Memory: uint(p.memory), Threads: uint(threads)}
+	}
+
+	return nil
+
+}
+
+// calibration holds the parameters found by calibrate and the time
+// measured with them
+type calibration struct {
+	rounds, passes, memory uint32
+	measured               time.Duration
+}
+
+// calibrate searches for the parameters of a derivation taking target,
+// using at most memory KiB, with measure timing each candidate
+func calibrate(target time.Duration, memory uint32, threads uint8, measure func(rounds, passes, memory uint32, threads uint8) (time.Duration, error)) (*calibration, error) {
+
+	// PBKDF2 is given a tenth of the target, Argon2 the rest
+	sample, err := measure(sampleRounds, 1, minMemoryPerThread, 1)
+	if err != nil {
+		return nil, err
+	}
+	rounds := uint32(float64(sampleRounds) * float64(target/10) / float64(sample))
+	if rounds < 1000 {
+		rounds = 1000
+	}
+
+	// prefer memory over passes, halving it only if one pass is too slow
+	var first time.Duration
+	for {
+		if first, err = measure(rounds, 1, memory, threads); err != nil {
+			return nil, err
+		}
+		if first <= target || memory/2 < minMemoryPerThread*uint32(threads) {
+			break
+		}
+		memory /= 2
+	}
+
+	// the first pass also pays for PBKDF2 and allocation, so time a second
+	second, err := measure(rounds, 2, memory, threads)
+	if err != nil {
+		return nil, err
+	}
+
+	passes := uint32(1)
+	if pass := second - first; pass > 0 && target > first {
+		passes += uint32((target - first) / pass)
+	}
+
+	// measurements are noisy, so rescale the passes a few times by the
+	// duration actually measured
+	var actual time.Duration
+	for i := 0; i < 3; i++ {
+		if actual, err = measure(rounds, passes, memory, threads); err != nil {
+			return nil, err
+		}
+		scaled := uint32(float64(passes) * float64(target) / float64(actual))
+		if scaled < 1 {
+			scaled = 1
+		}
+		if scaled == passes || i == 2 {
+			break
+		}
+		passes = scaled
+	}
+
+	return &calibration{rounds: rounds, passes: passes, memory: memory, measured: actual}, nil
+
+}
+
+// measureDerivation returns the time taken by a single derivation with the given parameters
+func measureDerivation(rounds, passes, memory uint32, threads uint8) (time.Duration, error) {
+
+	seeder, err := slowseeder.New([]byte("ssh-keydgen calibration"), rounds, passes, memory, threads)
+	if err != nil {
+		return 0, newError("Error with calibration parameters: " + err.Error())
+	}
+	defer seeder.Close()
+
+	start := time.Now()
+	if _, err = seeder.Read(make([]byte, 32)); err != nil {
+		return 0, newError("Error measuring derivation: " + err.Error())
+	}
+
+	return time.Since(start), nil
+
+}
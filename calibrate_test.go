@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeDerivation times a derivation as PBKDF2 rounds plus Argon2 passes
+// over memory, at the given costs
+func fakeDerivation(perRound, perKiB time.Duration) func(rounds, passes, memory uint32, threads uint8) (time.Duration, error) {
+	return func(rounds, passes, memory uint32, threads uint8) (time.Duration, error) {
+		return time.Duration(rounds)*perRound + time.Duration(passes)*time.Duration(memory)*perKiB/time.Duration(threads), nil
+	}
+}
+
+func TestCalibrate(t *testing.T) {
+
+	const target = 2 * time.Second
+
+	cases := []struct {
+		name           string
+		perRound       time.Duration
+		perKiB         time.Duration
+		maxMemory      uint32
+		halved, passes bool
+	}{
+		{"memory fits", time.Microsecond, 100 * time.Nanosecond, 1024 * 1024, false, true},
+		{"memory halved", time.Microsecond, 10 * time.Microsecond, 1024 * 1024, true, false},
+	}
+
+	for _, c := range cases {
+
+		p, err := calibrate(target, c.maxMemory, 1, fakeDerivation(c.perRound, c.perKiB))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if p.rounds < 1000 {
+			t.Errorf("%s: expected at least 1000 rounds, got %d", c.name, p.rounds)
+		}
+
+		if halved := p.memory < c.maxMemory; halved != c.halved {
+			t.Errorf("%s: expected memory halved %v, got %d KiB", c.name, c.halved, p.memory)
+		}
+
+		if c.passes && p.passes < 2 {
+			t.Errorf("%s: expected passes to fill the target, got %d", c.name, p.passes)
+		}
+
+		// halving memory can undershoot by up to half, but never overshoots
+		if p.measured < target/2 || p.measured > target*11/10 {
+			t.Errorf("%s: expected about %s, measured %s with %+v", c.name, target, p.measured, p)
+		}
+
+	}
+
+}
+
+func TestCalibrateError(t *testing.T) {
+
+	failure := errors.New("measurement failed")
+	measure := func(rounds, passes, memory uint32, threads uint8) (time.Duration, error) {
+		return 0, failure
+	}
+
+	if _, err := calibrate(time.Second, 1024, 1, measure); err != failure {
+		t.Fatalf("expected the measurement error, got %v", err)
+	}
+
+}
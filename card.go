@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cornfeedhobo/ssh-keydgen/keygen"
+	"github.com/cornfeedhobo/ssh-keydgen/qrcode"
+	"github.com/cornfeedhobo/ssh-keydgen/recovery"
+	"github.com/cornfeedhobo/ssh-keydgen/seedphrase"
+	"github.com/cornfeedhobo/ssh-keydgen/slowseeder"
+	"gopkg.in/urfave/cli.v1"
+)
+
+func newRecoveryCard(ctx *cli.Context, k *keygen.Keydgen) (*recovery.Card, error) {
+
+	fingerprint, err := k.Fingerprint()
+	if err != nil {
+		return nil, err
+	}
+
+	return &recovery.Card{
+		Version:     ctx.App.Version,
+		Scheme:      derivationScheme(ctx),
+		Type:        k.Type,
+		Bits:        k.Bits,
+		Curve:       k.Curve,
+		Rounds:      uint32(ctx.Int("a")),
+		Time:        uint32(ctx.Uint("at")),
+		Memory:      uint32(ctx.Uint("am")),
+		Threads:     uint8(ctx.Uint("ap")),
+		Label:       ctx.String("al"),
+		Comment:     k.Comment,
+		Fingerprint: fingerprint,
+		Mnemonic:    ctx.Bool("mnemonic") || ctx.Int("gen-mnemonic") != 0,
+	}, nil
+
+}
+
+func writeRecoveryCard(ctx *cli.Context, k *keygen.Keydgen) error {
+
+	card, err := newRecoveryCard(ctx, k)
+	if err != nil {
+		return newError("Error creating recovery card: " + err.Error())
+	}
+
+	filename := ctx.String("card")
+
+	buf := bytes.NewBuffer(nil)
+	if strings.ToLower(filepath.Ext(filename)) == ".svg" {
+		err = card.WriteSVG(buf)
+	} else {
+		err = card.WriteText(buf)
+	}
+	if err != nil {
+		return newError("Error creating recovery card: " + err.Error())
+	}
+
+	if err = replaceFile(filename, buf.Bytes(), 0600); err != nil {
+		return newErrorKind(kindIO, "Error writing recovery card: "+err.Error())
+	}
+
+	return nil
+
+}
+
+func writeRecoveryQRCode(ctx *cli.Context, k *keygen.Keydgen) error {
+
+	card, err := newRecoveryCard(ctx, k)
+	if err != nil {
+		return newError("Error creating recovery card: " + err.Error())
+	}
+
+	code, err := qrcode.Encode([]byte(card.Encode()), qrcode.Medium)
+	if err != nil {
+		return newError("Error encoding QR code: " + err.Error())
+	}
+
+	if ctx.Bool("qr") {
+		if err = code.WriteANSI(stdout); err != nil {
+			return newError(err.Error())
+		}
+	}
+
+	filename := ctx.String("qr-file")
+	if filename == "" {
+		return nil
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if strings.ToLower(filepath.Ext(filename)) == ".svg" {
+		err = code.WriteSVG(buf, 8)
+	} else {
+		err = code.WritePNG(buf, 8)
+	}
+	if err != nil {
+		return newError("Error encoding QR code: " + err.Error())
+	}
+
+	if err = replaceFile(filename, buf.Bytes(), 0600); err != nil {
+		return newErrorKind(kindIO, "Error writing QR code: "+err.Error())
+	}
+
+	return nil
+
+}
+
+// derivationScheme identifies every step turning a seedphrase into key material
+func derivationScheme(ctx *cli.Context) string {
+
+	scheme := slowseeder.Scheme
+
+	form, _ := seedphrase.ParseForm(ctx.String("normalize"))
+	if form.Scheme() != "" {
+		scheme += "+" + form.Scheme()
+	}
+
+	if ctx.String("keyfile") != "" {
+		scheme += "+" + slowseeder.KeyfileScheme
+	}
+
+	return scheme
+
+}
+
+// derivationLabel returns the label the key of keyType is derived under.
+// age and WireGuard keys are derived under the label with "/age" or
+// "/wireguard" appended, as they would otherwise be made of the same bytes
+// as the ed25519 key, and of each other.
+func derivationLabel(label, keyType string) string {
+
+	switch keyType {
+	case keygen.AGE:
+		return label + "/age"
+	case keygen.WIREGUARD:
+		return label + "/wireguard"
+	}
+
+	return label
+
+}
+
+// parseDerivationScheme returns the seedphrase form of scheme and whether it requires a keyfile
+func parseDerivationScheme(scheme string) (form seedphrase.Form, keyfile bool, err error) {
+
+	var (
+		parts       = strings.Split(scheme, "+")
+		unsupported = newErrorKind(kindUsage, "Unsupported derivation scheme "+strconv.Quote(scheme))
+	)
+
+	if parts[0] != slowseeder.Scheme {
+		return "", false, unsupported
+	}
+
+	form = seedphrase.Raw
+	for _, part := range parts[1:] {
+		if part == slowseeder.KeyfileScheme {
+			keyfile = true
+		} else if form, err = seedphrase.ParseScheme(part); err != nil || form == seedphrase.Raw {
+			return "", false, unsupported
+		}
+	}
+
+	return form, keyfile, nil
+
+}
+
+// checkImportedKeyfile fails if the imported key was derived with a
+// keyfile and none was given, by flag or profile
+func checkImportedKeyfile(ctx *cli.Context, card *recovery.Card) error {
+
+	if card == nil {
+		return nil
+	}
+
+	_, keyfile, err := parseDerivationScheme(card.Scheme)
+	if err != nil {
+		return err
+	}
+
+	if keyfile && ctx.String("keyfile") == "" {
+		return newErrorKind(kindUsage, "This key was derived with a keyfile, supply it with --keyfile")
+	}
+
+	return nil
+
+}
+
+// applyImport decodes the string given with --import and uses its
+// parameters for every flag that was not explicitly provided
+func applyImport(ctx *cli.Context) (*recovery.Card, error) {
+
+	blob := ctx.String("import")
+	if blob == "" {
+		return nil, nil
+	}
+
+	card, err := recovery.Decode(blob)
+	if err != nil {
+		return nil, newErrorKind(kindUsage, "Error importing recovery string: "+err.Error())
+	}
+
+	form, _, err := parseDerivationScheme(card.Scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := []struct {
+		flag, value string
+		set         bool
+	}{
+		{"t", card.Type, true},
+		{"normalize", string(form), true},
+		{"b", strconv.Itoa(int(card.Bits)), card.Bits != 0},
+		{"c", strconv.Itoa(int(card.Curve)), card.Curve != 0},
+		{"a", strconv.FormatUint(uint64(card.Rounds), 10), card.Rounds != 0},
+		{"at", strconv.FormatUint(uint64(card.Time), 10), card.Time != 0},
+		{"am", strconv.FormatUint(uint64(card.Memory), 10), card.Memory != 0},
+		{"ap", strconv.FormatUint(uint64(card.Threads), 10), card.Threads != 0},
+		{"al", card.Label, card.Label != ""},
+		{"C", card.Comment, card.Comment != ""},
+		{"mnemonic", "true", card.Mnemonic},
+	}
+
+	for _, s := range settings {
+		if !s.set || ctx.IsSet(s.flag) {
+			continue
+		}
+		if err := ctx.Set(s.flag, s.value); err != nil {
+			return nil, newErrorKind(kindUsage, "Invalid value for "+s.flag+" in recovery string: "+err.Error())
+		}
+	}
+
+	return card, nil
+
+}
+
+func verifyFingerprint(k *keygen.Keydgen, expected string) error {
+
+	fingerprint, err := k.Fingerprint()
+	if err != nil {
+		return newError(err.Error())
+	}
+
+	if fingerprint != expected {
+		return newErrorKind(kindFingerprint, "Generated key "+fingerprint+" does not match the expected "+expected+", check the seedphrase and parameters")
+	}
+
+	return nil
+
+}
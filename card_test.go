@@ -0,0 +1,126 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cornfeedhobo/ssh-keydgen/keygen"
+	"github.com/cornfeedhobo/ssh-keydgen/seedphrase"
+	"github.com/cornfeedhobo/ssh-keydgen/slowseeder"
+)
+
+// testKey derives a small ed25519 key for tests
+func testKey(t *testing.T) *keygen.Keydgen {
+
+	d, err := slowseeder.New([]byte("keygen"), 1, 1, 512, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	k := &keygen.Keydgen{Type: keygen.ED25519}
+	if _, err = k.GenerateKey(d); err != nil {
+		t.Fatal(err)
+	}
+
+	return k
+
+}
+
+func TestWriteRecoveryCard(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "ssh-keydgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	k := testKey(t)
+	defer k.Wipe()
+
+	filename := filepath.Join(dir, "card.txt")
+	if err = writeRecoveryCard(testContext("-card", filename, "-al", "github.com"), k); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "github.com") {
+		t.Fatalf("unexpected card:\n%s", b)
+	}
+
+	// a symlink planted at the card path is never followed
+	target := filepath.Join(dir, "target")
+	if err = ioutil.WriteFile(target, []byte("untouched"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "link.svg")
+	if err = os.Symlink(target, link); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+	if err = writeRecoveryCard(testContext("-card", link), k); err == nil {
+		t.Fatal("expected writing through a symlink to fail")
+	}
+	if b, _ = ioutil.ReadFile(target); string(b) != "untouched" {
+		t.Fatalf("symlink target was changed to %q", b)
+	}
+
+}
+
+func TestRecoveryQRCodeImport(t *testing.T) {
+
+	cases := []struct {
+		key  keygen.Keydgen
+		args []string
+	}{
+		{keygen.Keydgen{Type: keygen.ECDSA, Curve: 384}, []string{"-t", "ecdsa", "-c", "384"}},
+		{keygen.Keydgen{Type: keygen.RSA, Bits: 1024}, []string{"-t", "rsa", "-b", "1024"}},
+	}
+
+	for _, c := range cases {
+
+		d, err := slowseeder.New([]byte("keygen"), 1, 1, 512, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		k := c.key
+		_, err = k.GenerateKey(d)
+		d.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ctx := testContext(append(c.args, "-a", "1234", "-at", "3", "-am", "2048", "-ap", "2", "-al", "github.com", "-normalize", "nfc")...)
+		card, err := newRecoveryCard(ctx, &k)
+		k.Wipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the string encoded in the QR code restores every parameter when imported
+		imported := testContext("-import", card.Encode())
+		if _, err = applyImport(imported); err != nil {
+			t.Fatal(err)
+		}
+		for _, flag := range []string{"t", "b", "c", "a", "at", "am", "ap", "al", "normalize"} {
+			if imported.String(flag) != ctx.String(flag) {
+				t.Errorf("%s: expected -%s %q, got %q", c.key.Type, flag, ctx.String(flag), imported.String(flag))
+			}
+		}
+
+		form, keyfile, err := parseDerivationScheme(card.Scheme)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if form != seedphrase.NFC || keyfile {
+			t.Errorf("%s: unexpected derivation scheme %q", c.key.Type, card.Scheme)
+		}
+
+	}
+
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/cornfeedhobo/ssh-keydgen/keygen"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// keyExtra writes another file made from the generated key, alongside the
+// key files
+type keyExtra interface {
+	// check returns an error unless the extra can be made for keyType,
+	// before any time is spent deriving the key
+	check(keyType string) error
+	// write writes the extra for the key written to filename, which is
+	// empty when the key went to the agent, and returns the file written
+	write(k *keygen.Keydgen, filename string) (string, error)
+}
+
+var keyUsages = map[string]x509.KeyUsage{
+	"digitalsignature":  x509.KeyUsageDigitalSignature,
+	"contentcommitment": x509.KeyUsageContentCommitment,
+	"keyencipherment":   x509.KeyUsageKeyEncipherment,
+	"dataencipherment":  x509.KeyUsageDataEncipherment,
+	"keyagreement":      x509.KeyUsageKeyAgreement,
+	"certsign":          x509.KeyUsageCertSign,
+	"crlsign":           x509.KeyUsageCRLSign,
+}
+
+var extKeyUsages = map[string]x509.ExtKeyUsage{
+	"any":             x509.ExtKeyUsageAny,
+	"serverauth":      x509.ExtKeyUsageServerAuth,
+	"clientauth":      x509.ExtKeyUsageClientAuth,
+	"codesigning":     x509.ExtKeyUsageCodeSigning,
+	"emailprotection": x509.ExtKeyUsageEmailProtection,
+	"timestamping":    x509.ExtKeyUsageTimeStamping,
+	"ocspsigning":     x509.ExtKeyUsageOCSPSigning,
+}
+
+// subjectAttributes are the distinguished name attributes accepted by --subject
+var subjectAttributes = map[string]asn1.ObjectIdentifier{
+	"CN": {2, 5, 4, 3},
+	"O":  {2, 5, 4, 10},
+	"OU": {2, 5, 4, 11},
+	"L":  {2, 5, 4, 7},
+	"ST": {2, 5, 4, 8},
+	"C":  {2, 5, 4, 6},
+}
+
+// certificateExtra writes a self-signed certificate for the key
+type certificateExtra struct {
+	template *keygen.CertificateTemplate
+	filename string
+}
+
+func x509Action(ctx *cli.Context) error {
+
+	template, err := newCertificateTemplate(ctx)
+	if err != nil {
+		return err
+	}
+
+	if ctx.Parent().Bool("aa") && ctx.String("cert") == "" {
+		return newErrorKind(kindUsage, "A certificate file is required with --aa, use --cert")
+	}
+
+	return generate(ctx.Parent(), getSeedphrase, &certificateExtra{
+		template: template,
+		filename: ctx.String("cert"),
+	})
+
+}
+
+func (c *certificateExtra) check(keyType string) error {
+
+	if keyType == keygen.DSA || !keygen.SSHKeyType(keyType) {
+		return newErrorKind(kindUsage, "Key type "+keyType+" can not be used for X.509 certificates")
+	}
+
+	return nil
+
+}
+
+func (c *certificateExtra) write(k *keygen.Keydgen, filename string) (string, error) {
+
+	if c.filename != "" {
+		filename = c.filename
+	} else {
+		filename += ".crt"
+	}
+
+	b, err := k.MarshalCertificate(c.template)
+	if err != nil {
+		return "", newError("Error creating certificate: " + err.Error())
+	}
+
+	if err = replaceFile(filename, b, 0644); err != nil {
+		return "", newErrorKind(kindIO, "Error writing certificate: "+err.Error())
+	}
+
+	return filename, nil
+
+}
+
+// newCertificateTemplate returns the certificate described by the x509 flags
+func newCertificateTemplate(ctx *cli.Context) (*keygen.CertificateTemplate, error) {
+
+	if ctx.String("not-before") == "" {
+		return nil, newErrorKind(kindUsage, "A start date is required so the certificate can be reproduced, use --not-before")
+	}
+
+	notBefore, err := parseDate(ctx.String("not-before"))
+	if err != nil {
+		return nil, err
+	}
+
+	if ctx.Int("days") < 1 {
+		return nil, newErrorKind(kindUsage, "The certificate must be valid for at least one day")
+	}
+
+	template := &keygen.CertificateTemplate{
+		NotBefore: notBefore,
+		NotAfter:  notBefore.AddDate(0, 0, ctx.Int("days")),
+		IsCA:      ctx.Bool("ca"),
+	}
+
+	if template.Subject, err = parseSubject(ctx.String("subject")); err != nil {
+		return nil, err
+	}
+
+	for _, name := range ctx.StringSlice("san") {
+		switch ip := net.ParseIP(name); {
+		case ip != nil:
+			template.IPAddresses = append(template.IPAddresses, ip)
+		case strings.Contains(name, "://"):
+			template.URIs = append(template.URIs, name)
+		case strings.Contains(name, "@"):
+			template.EmailAddresses = append(template.EmailAddresses, name)
+		default:
+			template.DNSNames = append(template.DNSNames, name)
+		}
+	}
+
+	if ctx.String("subject") == "" && len(ctx.StringSlice("san")) == 0 {
+		return nil, newErrorKind(kindUsage, "A certificate needs a subject or at least one subject alternative name")
+	}
+
+	for _, name := range splitList(ctx.String("key-usage")) {
+		usage, ok := keyUsages[strings.ToLower(name)]
+		if !ok {
+			return nil, newErrorKind(kindUsage, "Unsupported key usage: "+name)
+		}
+		template.KeyUsage |= usage
+	}
+
+	for _, name := range splitList(ctx.String("ext-key-usage")) {
+		usage, ok := extKeyUsages[strings.ToLower(name)]
+		if !ok {
+			return nil, newErrorKind(kindUsage, "Unsupported extended key usage: "+name)
+		}
+		template.ExtKeyUsage = append(template.ExtKeyUsage, usage)
+	}
+
+	return template, nil
+
+}
+
+// parseDate parses a date given as YYYY-MM-DD or RFC 3339
+func parseDate(value string) (time.Time, error) {
+
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		if date, err = time.Parse(time.RFC3339, value); err != nil {
+			return date, newErrorKind(kindUsage, "Invalid date, expected YYYY-MM-DD or RFC 3339: "+value)
+		}
+	}
+
+	return date, nil
+
+}
+
+// parseSubject parses a comma separated distinguished name such as
+// "CN=host,O=Example", or a bare common name. Attributes keep the order
+// they were given in.
+func parseSubject(subject string) (pkix.Name, error) {
+
+	var name pkix.Name
+
+	if subject != "" && !strings.Contains(subject, "=") {
+		name.CommonName = subject
+		return name, nil
+	}
+
+	// ExtraNames are encoded as given, unlike the named fields which
+	// pkix.Name sorts into a fixed order
+	for _, attribute := range splitList(subject) {
+
+		parts := strings.SplitN(attribute, "=", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return name, newErrorKind(kindUsage, "Invalid subject attribute: "+attribute)
+		}
+
+		oid, ok := subjectAttributes[strings.ToUpper(strings.TrimSpace(parts[0]))]
+		if !ok {
+			return name, newErrorKind(kindUsage, "Unsupported subject attribute: "+parts[0])
+		}
+
+		name.ExtraNames = append(name.ExtraNames, pkix.AttributeTypeAndValue{
+			Type:  oid,
+			Value: strings.TrimSpace(parts[1]),
+		})
+
+	}
+
+	return name, nil
+
+}
+
+// splitList splits a comma separated list, dropping empty items
+func splitList(list string) []string {
+
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/cornfeedhobo/ssh-keydgen/keygen"
+)
+
+func TestParseSubject(t *testing.T) {
+
+	subject, err := parseSubject("CN=host, O=Example, OU=Ops, C=US")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k := testKey(t)
+	defer k.Wipe()
+
+	b, err := k.MarshalCertificate(&keygen.CertificateTemplate{
+		Subject:   subject,
+		NotBefore: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:  time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		t.Fatal("expected a PEM certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the certificate carries the attributes in the order they were given
+	var rdns pkix.RDNSequence
+	if _, err = asn1.Unmarshal(cert.RawSubject, &rdns); err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct {
+		oid   asn1.ObjectIdentifier
+		value string
+	}{
+		{subjectAttributes["CN"], "host"},
+		{subjectAttributes["O"], "Example"},
+		{subjectAttributes["OU"], "Ops"},
+		{subjectAttributes["C"], "US"},
+	}
+	if len(rdns) != len(expected) {
+		t.Fatalf("expected %d attributes, got %v", len(expected), rdns)
+	}
+	for i, e := range expected {
+		if atv := rdns[i][0]; !atv.Type.Equal(e.oid) || atv.Value != e.value {
+			t.Errorf("attribute %d: expected %v=%s, got %v=%v", i, e.oid, e.value, atv.Type, atv.Value)
+		}
+	}
+
+	if cert.Subject.CommonName != "host" || cert.Issuer.CommonName != "host" {
+		t.Errorf("unexpected subject %v and issuer %v", cert.Subject, cert.Issuer)
+	}
+
+	if _, err = parseSubject("CN=host,DC=example"); err == nil {
+		t.Fatal("expected an unsupported attribute to fail")
+	}
+
+}
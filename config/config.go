@@ -0,0 +1,251 @@
+// Package config parses ssh-keydgen configuration files.
+//
+// A configuration file holds named profiles, each describing every
+// parameter needed to reproduce a key except the seedphrase itself.
+// The format is a small subset of TOML:
+//
+//	# comments start with a hash
+//	[github]
+//	type = "ed25519"
+//	rounds = 1000
+//	label = "github.com"
+//	comment = "me@example.com"
+//	file = "~/.ssh/id_github"
+//	mnemonic = true
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+var (
+	// ErrSeedphraseNotAllowed is the error returned when a profile attempts to store a seedphrase
+	ErrSeedphraseNotAllowed = errors.New("seedphrases must never be stored in a configuration file")
+
+	// ErrProfileNotFound is the error returned when a requested profile does not exist
+	ErrProfileNotFound = errors.New("profile not found")
+)
+
+// Profile represents a named set of key generation parameters.
+// Zero values mean the parameter was not specified.
+type Profile struct {
+	Name      string
+	Type      string
+	Bits      int
+	Curve     int
+	Rounds    int
+	Time      uint
+	Memory    uint
+	Threads   uint
+	Label     string
+	Comment   string
+	File      string
+	Normalize string
+	Keyfile   string
+	Mnemonic  bool
+}
+
+// Config represents a parsed configuration file
+type Config struct {
+	Profiles map[string]*Profile
+}
+
+// DefaultPath returns the default location of the configuration file
+func DefaultPath() (string, error) {
+
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "ssh-keydgen", "config.toml"), nil
+	}
+
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, ".config", "ssh-keydgen", "config.toml"), nil
+
+}
+
+// Load reads and parses the configuration file at path
+func Load(path string) (*Config, error) {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Parse(f)
+
+}
+
+// Parse parses a configuration file from r
+func Parse(r io.Reader) (*Config, error) {
+
+	var (
+		c       = &Config{Profiles: map[string]*Profile{}}
+		profile *Profile
+		scanner = bufio.NewScanner(r)
+		lineno  int
+	)
+
+	for scanner.Scan() {
+
+		lineno++
+
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: malformed profile header", lineno)
+			}
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			if name == "" {
+				return nil, fmt.Errorf("line %d: empty profile name", lineno)
+			}
+			if _, ok := c.Profiles[name]; ok {
+				return nil, fmt.Errorf("line %d: duplicate profile %q", lineno, name)
+			}
+			profile = &Profile{Name: name}
+			c.Profiles[name] = profile
+			continue
+		}
+
+		if profile == nil {
+			return nil, fmt.Errorf("line %d: setting outside of a profile", lineno)
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("line %d: expected key = value", lineno)
+		}
+
+		key := strings.ToLower(strings.TrimSpace(parts[0]))
+		value, err := parseValue(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", lineno, err)
+		}
+
+		if err := profile.set(key, value); err != nil {
+			return nil, fmt.Errorf("line %d: %s", lineno, err)
+		}
+
+	}
+
+	return c, scanner.Err()
+
+}
+
+// Profile returns the named profile
+func (c *Config) Profile(name string) (*Profile, error) {
+	p, ok := c.Profiles[name]
+	if !ok {
+		return nil, ErrProfileNotFound
+	}
+	return p, nil
+}
+
+func (p *Profile) set(key, value string) (err error) {
+
+	switch key {
+	case "type":
+		p.Type = value
+	case "bits":
+		p.Bits, err = strconv.Atoi(value)
+	case "curve":
+		p.Curve, err = strconv.Atoi(value)
+	case "rounds":
+		p.Rounds, err = strconv.Atoi(value)
+	case "time":
+		p.Time, err = parseUint(value)
+	case "memory":
+		p.Memory, err = parseUint(value)
+	case "threads":
+		p.Threads, err = parseUint(value)
+	case "label", "salt":
+		p.Label = value
+	case "comment":
+		p.Comment = value
+	case "file":
+		p.File = value
+	case "keyfile":
+		p.Keyfile = value
+	case "normalize":
+		p.Normalize = value
+	case "mnemonic":
+		p.Mnemonic, err = strconv.ParseBool(value)
+	case "seedphrase", "seed", "passphrase":
+		err = ErrSeedphraseNotAllowed
+	default:
+		err = fmt.Errorf("unknown setting %q", key)
+	}
+
+	if numErr, ok := err.(*strconv.NumError); ok {
+		err = fmt.Errorf("invalid value for %s: %q", key, numErr.Num)
+	}
+
+	return
+
+}
+
+func parseUint(value string) (uint, error) {
+	n, err := strconv.ParseUint(value, 10, 32)
+	return uint(n), err
+}
+
+func parseValue(value string) (string, error) {
+
+	if value == "" {
+		return "", errors.New("missing value")
+	}
+
+	if value[0] != '"' && value[0] != '\'' {
+		return value, nil
+	}
+
+	if len(value) < 2 || value[len(value)-1] != value[0] {
+		return "", errors.New("unterminated string")
+	}
+
+	if value[0] == '\'' {
+		return value[1 : len(value)-1], nil
+	}
+
+	return strconv.Unquote(value)
+
+}
+
+func stripComment(line string) string {
+
+	var quote byte
+
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+
+	return line
+
+}
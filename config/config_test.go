@@ -0,0 +1,77 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+
+	c, err := Parse(strings.NewReader(`
+# team keys
+[github]
+type = "ed25519"
+rounds = 1000
+label = "github.com" # trailing comment
+comment = "me # not a comment"
+
+[prod-ca]
+type = 'rsa'
+bits = 4096
+time = 6
+memory = 65536
+threads = 2
+file = "~/.ssh/prod_ca"
+mnemonic = true
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := c.Profile("github")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Type != "ed25519" || p.Rounds != 1000 || p.Label != "github.com" || p.Comment != "me # not a comment" {
+		t.Fatalf("unexpected profile: %+v", p)
+	}
+
+	p, err = c.Profile("prod-ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Type != "rsa" || p.Bits != 4096 || p.Time != 6 || p.Memory != 65536 || p.Threads != 2 || p.File != "~/.ssh/prod_ca" || !p.Mnemonic {
+		t.Fatalf("unexpected profile: %+v", p)
+	}
+
+	if _, err = c.Profile("missing"); err != ErrProfileNotFound {
+		t.Fatalf("expected ErrProfileNotFound, got %v", err)
+	}
+
+}
+
+func TestParseErrors(t *testing.T) {
+
+	cases := map[string]string{
+		"seedphrase": "[a]\nseedphrase = \"hunter2\"",
+		"orphan":     "type = \"rsa\"",
+		"unknown":    "[a]\ncolor = \"red\"",
+		"number":     "[a]\nbits = lots",
+		"bool":       "[a]\nmnemonic = maybe",
+		"duplicate":  "[a]\n[a]",
+		"header":     "[a",
+		"string":     "[a]\ntype = \"rsa",
+	}
+
+	for name, input := range cases {
+		if _, err := Parse(strings.NewReader(input)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+
+	_, err := Parse(strings.NewReader(cases["seedphrase"]))
+	if err == nil || !strings.Contains(err.Error(), ErrSeedphraseNotAllowed.Error()) {
+		t.Fatalf("expected seedphrase error, got %v", err)
+	}
+
+}
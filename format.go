@@ -0,0 +1,204 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cornfeedhobo/ssh-keydgen/keygen"
+	"github.com/cornfeedhobo/ssh-keydgen/secret"
+	"github.com/cornfeedhobo/ssh-keydgen/slowseeder"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// key file formats selected with --format
+const (
+	formatSSH     = "ssh"
+	formatPKCS8   = "pkcs8"
+	formatPPK     = "ppk"
+	formatPPK2    = "ppk2"
+	formatJWK     = "jwk"
+	formatOpenPGP = "openpgp"
+)
+
+// keyFormat describes how key files are written
+type keyFormat struct {
+	name       string
+	passphrase []byte
+	pgp        *keygen.OpenPGPOptions
+	pgpSubkey  bool
+}
+
+// newKeyFormat returns the format selected with --format, along with the
+// passphrase named by --passphrase-env
+func newKeyFormat(ctx *cli.Context) (*keyFormat, error) {
+
+	f := &keyFormat{name: strings.ToLower(ctx.String("format"))}
+
+	switch f.name {
+	case formatSSH, formatPKCS8, formatPPK, formatPPK2, formatJWK, formatOpenPGP:
+	default:
+		return nil, newErrorKind(kindUsage, "Unsupported key format: "+ctx.String("format"))
+	}
+
+	if name := ctx.String("passphrase-env"); name != "" {
+
+		if f.name != formatPPK && f.name != formatPPK2 {
+			return nil, newErrorKind(kindUsage, "Only the "+formatPPK+" and "+formatPPK2+" formats can be encrypted with --passphrase-env, use ssh-keygen -p for the others")
+		}
+
+		f.passphrase = []byte(os.Getenv(name))
+		os.Unsetenv(name)
+
+		if len(f.passphrase) == 0 {
+			return nil, newErrorKind(kindUsage, "Passphrase from --passphrase-env is empty")
+		}
+
+	}
+
+	if f.name == formatOpenPGP {
+
+		if ctx.String("pgp-created") == "" {
+			return nil, newErrorKind(kindUsage, "A creation date is required so the OpenPGP fingerprint can be reproduced, use --pgp-created")
+		}
+
+		created, err := parseDate(ctx.String("pgp-created"))
+		if err != nil {
+			return nil, err
+		}
+
+		f.pgp = &keygen.OpenPGPOptions{UserID: ctx.String("pgp-uid"), Created: created}
+		f.pgpSubkey = ctx.Bool("pgp-subkey")
+
+	}
+
+	return f, nil
+
+}
+
+// check returns an error unless keys of keyType can be written in the format
+func (f *keyFormat) check(keyType string) error {
+
+	if keyType == keygen.DSA && (f.name == formatPKCS8 || f.name == formatJWK) {
+		return newErrorKind(kindUsage, "DSA keys can not be written as "+f.name)
+	}
+
+	if f.name == formatOpenPGP && keyType != keygen.ED25519 && keyType != keygen.RSA {
+		return newErrorKind(kindUsage, "Only ed25519 and rsa keys can be written as "+formatOpenPGP)
+	}
+
+	if !keygen.SSHKeyType(keyType) && f.name != formatSSH {
+		return newErrorKind(kindUsage, "Key type "+keyType+" is always written in its own format, not "+f.name)
+	}
+
+	return nil
+
+}
+
+// marshal returns the contents of the private and public key files
+func (f *keyFormat) marshal(k *keygen.Keydgen) (priv, pub []byte, err error) {
+
+	switch f.name {
+
+	case formatSSH:
+		priv, err = k.MarshalPrivateKey()
+
+	case formatPKCS8:
+		if priv, err = k.MarshalPKCS8PrivateKey(); err == nil {
+			pub, err = k.MarshalPKIXPublicKey()
+		}
+
+	case formatPPK:
+		priv, err = k.MarshalPPK(keygen.PPKv3, f.passphrase, rand.Reader)
+
+	case formatPPK2:
+		priv, err = k.MarshalPPK(keygen.PPKv2, f.passphrase, rand.Reader)
+
+	case formatJWK:
+		priv, pub, err = marshalJWK(k)
+
+	case formatOpenPGP:
+		// the user ID defaults to the comment, which batch sets per key
+		o := *f.pgp
+		if o.UserID == "" {
+			o.UserID = k.Comment
+		}
+		priv, pub, err = k.MarshalOpenPGP(&o)
+
+	default:
+		err = keygen.ErrUnsupportedFormat
+
+	}
+
+	// servers still want the OpenSSH public key alongside a PuTTY key
+	if err == nil && pub == nil {
+		pub, err = k.MarshalPublicKey()
+	}
+
+	if err != nil {
+		secret.Wipe(priv)
+		return nil, nil, newError(err.Error())
+	}
+
+	return priv, pub, nil
+
+}
+
+// marshalJWK returns the private and public JSON Web Keys
+func marshalJWK(k *keygen.Keydgen) (priv, pub []byte, err error) {
+
+	j, err := k.JWK()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer j.Wipe()
+
+	if priv, err = json.MarshalIndent(j, "", "  "); err != nil {
+		return nil, nil, err
+	}
+
+	if pub, err = json.MarshalIndent(j.Public(), "", "  "); err != nil {
+		return priv, nil, err
+	}
+
+	return append(priv, '\n'), append(pub, '\n'), nil
+
+}
+
+// deriveSubkey derives the OpenPGP encryption subkey, when requested, from
+// the seedphrase under the label with "/openpgp-encryption" appended
+func (f *keyFormat) deriveSubkey(ctx *cli.Context, seedphrase []byte) error {
+
+	if !f.pgpSubkey {
+		return nil
+	}
+
+	label := ctx.String("al") + "/openpgp-encryption"
+	seeder, err := slowseeder.NewWithLabel(seedphrase, []byte(label), uint32(ctx.Int("a")), uint32(ctx.Uint("at")), uint32(ctx.Uint("am")), uint8(ctx.Uint("ap")))
+	if err != nil {
+		return newErrorKind(kindUsage, "Error with supplied parameters: "+err.Error())
+	}
+	defer seeder.Close()
+
+	fmt.Fprintln(stdout, "Generating Curve25519 encryption subkey")
+
+	// any X25519 key will do, the subkey is clamped when it is written
+	f.pgp.Encryption = &keygen.Keydgen{Type: keygen.AGE}
+	_, err = generateKey(f.pgp.Encryption, seeder)
+
+	return err
+
+}
+
+// wipe zeroes the passphrase and subkey
+func (f *keyFormat) wipe() {
+
+	secret.Wipe(f.passphrase)
+
+	if f.pgp != nil && f.pgp.Encryption != nil {
+		f.pgp.Encryption.Wipe()
+	}
+
+}
@@ -0,0 +1,72 @@
+package keygen
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/cornfeedhobo/ssh-keydgen/secret"
+	"golang.org/x/crypto/curve25519"
+)
+
+// x25519Key is an X25519 private key, the scalar before clamping
+type x25519Key [32]byte
+
+func generateX25519(rand io.Reader) (*x25519Key, error) {
+
+	key := new(x25519Key)
+	if _, err := io.ReadFull(rand, key[:]); err != nil {
+		secret.Wipe(key[:])
+		return nil, err
+	}
+
+	return key, nil
+
+}
+
+// public returns the X25519 public key
+func (key *x25519Key) public() []byte {
+
+	var pub [32]byte
+	curve25519.ScalarBaseMult(&pub, (*[32]byte)(key))
+
+	return pub[:]
+
+}
+
+// AgeRecipient returns the age1 recipient of an age identity, which others
+// encrypt to
+func (k *Keydgen) AgeRecipient() (string, error) {
+
+	if k.privateKey == nil {
+		panic("private key has not been generated yet")
+	}
+
+	key, ok := k.privateKey.(*x25519Key)
+	if !ok || k.Type != AGE {
+		return "", ErrUnsupportedKeyType
+	}
+
+	return string(bech32Encode("age", key.public())), nil
+
+}
+
+// MarshalAgeIdentity returns an age identity file, as written by age-keygen
+// but without the creation time, which would differ on every run
+func (k *Keydgen) MarshalAgeIdentity() ([]byte, error) {
+
+	recipient, err := k.AgeRecipient()
+	if err != nil {
+		return nil, err
+	}
+
+	identity := bech32Encode("AGE-SECRET-KEY-", k.privateKey.(*x25519Key)[:])
+	defer secret.Wipe(identity)
+
+	buf := bytes.NewBuffer(make([]byte, 0, 64+len(identity)+len(recipient)))
+	buf.WriteString("# public key: " + recipient + "\n")
+	buf.Write(identity)
+	buf.WriteString("\n")
+
+	return buf.Bytes(), nil
+
+}
@@ -0,0 +1,79 @@
+package keygen
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// the valid examples of BIP 173
+func TestBech32(t *testing.T) {
+
+	values := make([]byte, 32)
+	for i := range values {
+		values[i] = byte(i)
+	}
+
+	cases := []struct {
+		hrp      string
+		values   []byte
+		expected string
+	}{
+		{"A", nil, "A12UEL5L"},
+		{"a", nil, "a12uel5l"},
+		{"abcdef", values, "abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw"},
+	}
+
+	for _, c := range cases {
+		if encoded := string(bech32EncodeValues(c.hrp, c.values)); encoded != c.expected {
+			t.Fatalf("expected %s, got %s", c.expected, encoded)
+		}
+	}
+
+}
+
+// the X25519 keys of RFC 7748 section 6.1
+func TestAge(t *testing.T) {
+
+	scalar, _ := hex.DecodeString("77076d0a7318a57d3c16c17251b26645df4c2f87ebc0992ab177fba51db92c2a")
+	public, _ := hex.DecodeString("8520f0098930a754748b7ddcb43ef75a0dbf3a0d26381af4eba4a98eaa9b4e6a")
+
+	k := &Keydgen{Type: AGE}
+	if _, err := k.GenerateKey(bytes.NewReader(scalar)); err != nil {
+		t.Fatal(err)
+	}
+
+	recipient, err := k.AgeRecipient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := string(bech32Encode("age", public)); recipient != expected {
+		t.Fatalf("expected %s, got %s", expected, recipient)
+	}
+	if !strings.HasPrefix(recipient, "age1") || len(recipient) != 62 {
+		t.Fatalf("unexpected recipient %s", recipient)
+	}
+
+	identity, err := k.MarshalPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(string(identity), "\n")
+	if len(lines) != 3 || lines[0] != "# public key: "+recipient || lines[1] != string(bech32Encode("AGE-SECRET-KEY-", scalar)) {
+		t.Fatalf("unexpected identity file %q", identity)
+	}
+	if !strings.HasPrefix(lines[1], "AGE-SECRET-KEY-1") || lines[1] != strings.ToUpper(lines[1]) {
+		t.Fatalf("unexpected identity %s", lines[1])
+	}
+
+	if fingerprint, _ := k.Fingerprint(); fingerprint != recipient {
+		t.Fatalf("expected the fingerprint to be the recipient, got %s", fingerprint)
+	}
+
+	k.Wipe()
+	if k.privateKey != nil {
+		t.Fatal("expected the key to be forgotten")
+	}
+
+}
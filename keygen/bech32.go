@@ -0,0 +1,109 @@
+package keygen
+
+import (
+	"strings"
+
+	"github.com/cornfeedhobo/ssh-keydgen/secret"
+)
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+var bech32Generator = [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+
+// bech32Encode returns data as a BIP 173 Bech32 string with the human
+// readable part hrp, in upper case if hrp is. Unlike BIP 173, the length
+// is not limited to 90 characters, as with age.
+func bech32Encode(hrp string, data []byte) []byte {
+
+	values := bech32ConvertBits(data)
+	defer secret.Wipe(values)
+
+	return bech32EncodeValues(hrp, values)
+
+}
+
+// bech32EncodeValues returns the Bech32 string of 5 bit values
+func bech32EncodeValues(hrp string, values []byte) []byte {
+
+	upper := hrp == strings.ToUpper(hrp) && hrp != strings.ToLower(hrp)
+	hrp = strings.ToLower(hrp)
+
+	// the checksum covers the expanded hrp, the data and six zeroes
+	checked := make([]byte, 0, len(hrp)*2+1+len(values)+6)
+	for i := 0; i < len(hrp); i++ {
+		checked = append(checked, hrp[i]>>5)
+	}
+	checked = append(checked, 0)
+	for i := 0; i < len(hrp); i++ {
+		checked = append(checked, hrp[i]&31)
+	}
+	checked = append(checked, values...)
+	checked = append(checked, 0, 0, 0, 0, 0, 0)
+	defer secret.Wipe(checked)
+
+	encoded := make([]byte, 0, len(hrp)+1+len(values)+6)
+	encoded = append(encoded, hrp+"1"...)
+	for _, v := range values {
+		encoded = append(encoded, bech32Charset[v])
+	}
+
+	mod := bech32Polymod(checked) ^ 1
+	for i := 0; i < 6; i++ {
+		encoded = append(encoded, bech32Charset[(mod>>uint(5*(5-i)))&31])
+	}
+
+	// in place, as the encoding may be a secret key
+	if upper {
+		for i, c := range encoded {
+			if c >= 'a' && c <= 'z' {
+				encoded[i] = c - 'a' + 'A'
+			}
+		}
+	}
+
+	return encoded
+
+}
+
+func bech32Polymod(values []byte) uint32 {
+
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := uint(0); i < 5; i++ {
+			if (top>>i)&1 == 1 {
+				chk ^= bech32Generator[i]
+			}
+		}
+	}
+
+	return chk
+
+}
+
+// bech32ConvertBits regroups bytes into 5 bit values, padding the last one with zeroes
+func bech32ConvertBits(data []byte) []byte {
+
+	var (
+		values []byte
+		acc    uint32
+		bits   uint
+	)
+
+	for _, b := range data {
+		acc = acc<<8 | uint32(b)
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			values = append(values, byte(acc>>bits)&31)
+		}
+	}
+
+	if bits > 0 {
+		values = append(values, byte(acc<<(5-bits))&31)
+	}
+
+	return values
+
+}
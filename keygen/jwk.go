@@ -0,0 +1,168 @@
+package keygen
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+
+	"github.com/cornfeedhobo/ssh-keydgen/secret"
+	"golang.org/x/crypto/ed25519"
+)
+
+// JWK is a JSON Web Key of RFC 7517, with the OKP members of RFC 8037
+type JWK struct {
+	Kty string   `json:"kty"`
+	Kid string   `json:"kid,omitempty"`
+	Use string   `json:"use,omitempty"`
+	Alg string   `json:"alg,omitempty"`
+	Crv string   `json:"crv,omitempty"`
+	X   jwkBytes `json:"x,omitempty"`
+	Y   jwkBytes `json:"y,omitempty"`
+	N   jwkBytes `json:"n,omitempty"`
+	E   jwkBytes `json:"e,omitempty"`
+	D   jwkBytes `json:"d,omitempty"`
+	P   jwkBytes `json:"p,omitempty"`
+	Q   jwkBytes `json:"q,omitempty"`
+	DP  jwkBytes `json:"dp,omitempty"`
+	DQ  jwkBytes `json:"dq,omitempty"`
+	QI  jwkBytes `json:"qi,omitempty"`
+}
+
+// JWKSet is a JSON Web Key Set of RFC 7517
+type JWKSet struct {
+	Keys []*JWK `json:"keys"`
+}
+
+// jwkBytes is marshaled as unpadded base64url, as are all binary JWK members
+type jwkBytes []byte
+
+func (b jwkBytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(b))
+}
+
+// JWK returns the private key as a JSON Web Key for signing, with the
+// RFC 7638 thumbprint as its kid. DSA keys are not supported.
+func (k *Keydgen) JWK() (*JWK, error) {
+
+	if k.privateKey == nil {
+		panic("private key has not been generated yet")
+	}
+
+	j := &JWK{Use: "sig"}
+
+	switch key := k.privateKey.(type) {
+
+	case *rsa.PrivateKey:
+		if len(key.Primes) != 2 {
+			return nil, ErrUnsupportedFormat
+		}
+		p, q := key.Primes[0], key.Primes[1]
+		one := big.NewInt(1)
+		j.Kty, j.Alg = "RSA", "RS256"
+		j.N = key.N.Bytes()
+		j.E = big.NewInt(int64(key.E)).Bytes()
+		j.D = key.D.Bytes()
+		j.P = p.Bytes()
+		j.Q = q.Bytes()
+		j.DP = new(big.Int).Mod(key.D, new(big.Int).Sub(p, one)).Bytes()
+		j.DQ = new(big.Int).Mod(key.D, new(big.Int).Sub(q, one)).Bytes()
+		j.QI = new(big.Int).ModInverse(q, p).Bytes()
+
+	case *ecdsa.PrivateKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		j.Kty, j.Crv = "EC", key.Curve.Params().Name
+		switch j.Crv {
+		case "P-256":
+			j.Alg = "ES256"
+		case "P-384":
+			j.Alg = "ES384"
+		case "P-521":
+			j.Alg = "ES512"
+		default:
+			return nil, ErrUnsuppontedCurve
+		}
+		j.X = leftPad(key.X.Bytes(), size)
+		j.Y = leftPad(key.Y.Bytes(), size)
+		j.D = leftPad(key.D.Bytes(), size)
+
+	case ed25519.PrivateKey:
+		j.Kty, j.Crv, j.Alg = "OKP", "Ed25519", "EdDSA"
+		j.X = append(jwkBytes(nil), key[ed25519.SeedSize:]...)
+		j.D = append(jwkBytes(nil), key[:ed25519.SeedSize]...)
+
+	default:
+		return nil, ErrUnsupportedFormat
+
+	}
+
+	j.Kid = j.Thumbprint()
+
+	return j, nil
+
+}
+
+// Public returns a copy of the key without its private members
+func (j *JWK) Public() *JWK {
+	return &JWK{
+		Kty: j.Kty,
+		Kid: j.Kid,
+		Use: j.Use,
+		Alg: j.Alg,
+		Crv: j.Crv,
+		X:   j.X,
+		Y:   j.Y,
+		N:   j.N,
+		E:   j.E,
+	}
+}
+
+// Thumbprint returns the RFC 7638 SHA-256 thumbprint of the key, which
+// only covers the required public members and so is the same for the
+// private and public key
+func (j *JWK) Thumbprint() string {
+
+	// the members in lexicographic order, without whitespace
+	buf := bytes.NewBuffer(nil)
+	member := func(name, value string) {
+		if buf.Len() == 0 {
+			buf.WriteString("{")
+		} else {
+			buf.WriteString(",")
+		}
+		buf.WriteString(`"` + name + `":"` + value + `"`)
+	}
+	encode := base64.RawURLEncoding.EncodeToString
+
+	switch j.Kty {
+	case "RSA":
+		member("e", encode(j.E))
+		member("kty", j.Kty)
+		member("n", encode(j.N))
+	case "EC":
+		member("crv", j.Crv)
+		member("kty", j.Kty)
+		member("x", encode(j.X))
+		member("y", encode(j.Y))
+	default:
+		member("crv", j.Crv)
+		member("kty", j.Kty)
+		member("x", encode(j.X))
+	}
+	buf.WriteString("}")
+
+	sum := sha256.Sum256(buf.Bytes())
+
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+
+}
+
+// Wipe zeroes the private members of the key
+func (j *JWK) Wipe() {
+	for _, b := range []jwkBytes{j.D, j.P, j.Q, j.DP, j.DQ, j.QI} {
+		secret.Wipe(b)
+	}
+}
@@ -0,0 +1,116 @@
+package keygen
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// the example of RFC 8037 appendix A
+func TestJWKEd25519(t *testing.T) {
+
+	seed, _ := hex.DecodeString("9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60")
+	k := &Keydgen{Type: ED25519, privateKey: ed25519.NewKeyFromSeed(seed)}
+
+	j, err := k.JWK()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(j.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"kty":"OKP","kid":"kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k","use":"sig","alg":"EdDSA","crv":"Ed25519","x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}`
+	if string(data) != expected {
+		t.Fatalf("expected %s, got %s", expected, data)
+	}
+
+	if encoded := base64.RawURLEncoding.EncodeToString(j.D); encoded != "nWGxne_9WmC6hEr0kuwsxERJxWl7MmkZcDusAxyuf2A" {
+		t.Fatalf("unexpected d %s", encoded)
+	}
+
+}
+
+// the example of RFC 7638 section 3.1
+func TestJWKThumbprint(t *testing.T) {
+
+	n, _ := base64.RawURLEncoding.DecodeString("0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw")
+	j := &JWK{Kty: "RSA", N: n, E: []byte{1, 0, 1}}
+
+	if kid := j.Thumbprint(); kid != "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs" {
+		t.Fatalf("unexpected thumbprint %s", kid)
+	}
+
+}
+
+func TestJWK(t *testing.T) {
+
+	cases := []*Keydgen{
+		{Type: RSA, Bits: 1024},
+		{Type: ECDSA, Curve: 256},
+		{Type: ECDSA, Curve: 384},
+		{Type: ECDSA, Curve: 521},
+	}
+
+	for _, k := range cases {
+
+		key, err := k.GenerateKey(rand.New(rand.NewSource(1)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		j, err := k.JWK()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if j.Kid != j.Public().Thumbprint() {
+			t.Fatalf("%s: kid is not the thumbprint of the public key", k.Type)
+		}
+
+		switch key := key.(type) {
+
+		case *rsa.PrivateKey:
+			p, q := new(big.Int).SetBytes(j.P), new(big.Int).SetBytes(j.Q)
+			if new(big.Int).Mul(p, q).Cmp(key.N) != 0 || new(big.Int).SetBytes(j.N).Cmp(key.N) != 0 {
+				t.Fatalf("%s: modulus does not match", k.Type)
+			}
+			key.Precompute()
+			if new(big.Int).SetBytes(j.DP).Cmp(key.Precomputed.Dp) != 0 ||
+				new(big.Int).SetBytes(j.DQ).Cmp(key.Precomputed.Dq) != 0 ||
+				new(big.Int).SetBytes(j.QI).Cmp(key.Precomputed.Qinv) != 0 {
+				t.Fatalf("%s: CRT values do not match", k.Type)
+			}
+
+		case *ecdsa.PrivateKey:
+			size := (key.Curve.Params().BitSize + 7) / 8
+			if len(j.X) != size || len(j.Y) != size || len(j.D) != size {
+				t.Fatalf("%s: coordinates are not %d bytes", k.Type, size)
+			}
+			if new(big.Int).SetBytes(j.D).Cmp(key.D) != 0 || !key.Curve.IsOnCurve(new(big.Int).SetBytes(j.X), new(big.Int).SetBytes(j.Y)) {
+				t.Fatalf("%s: key does not match", k.Type)
+			}
+
+		}
+
+		j.Wipe()
+		for _, b := range [][]byte{j.D, j.P, j.Q, j.DP, j.DQ, j.QI} {
+			for _, c := range b {
+				if c != 0 {
+					t.Fatalf("%s: private members were not wiped", k.Type)
+				}
+			}
+		}
+
+	}
+
+}
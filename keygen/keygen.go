@@ -3,6 +3,7 @@ package keygen
 
 import (
 	"bytes"
+	"context"
 	"crypto/dsa"
 	"crypto/ecdsa"
 	"crypto/elliptic"
@@ -14,6 +15,7 @@ import (
 	"io"
 	"math/big"
 
+	"github.com/cornfeedhobo/ssh-keydgen/secret"
 	"github.com/mikesmitty/edkey"
 	"golang.org/x/crypto/ed25519"
 	"golang.org/x/crypto/ssh"
@@ -21,12 +23,20 @@ import (
 
 // These constants represent the support key types
 const (
-	DSA     = "dsa"
-	ECDSA   = "ecdsa"
-	RSA     = "rsa"
-	ED25519 = "ed25519"
+	DSA       = "dsa"
+	ECDSA     = "ecdsa"
+	RSA       = "rsa"
+	ED25519   = "ed25519"
+	AGE       = "age"
+	WIREGUARD = "wireguard"
 )
 
+// SSHKeyType reports whether keys of keyType are SSH keys, rather than the
+// X25519 keys of age or WireGuard which only have their own formats
+func SSHKeyType(keyType string) bool {
+	return keyType != AGE && keyType != WIREGUARD
+}
+
 var (
 	// ErrUnsupportedKeyType is the error returned when an unsupported key type is requested
 	ErrUnsupportedKeyType = errors.New("unsupported key type")
@@ -40,13 +50,27 @@ var (
 
 // Keydgen represents an OpenSSH key generator
 type Keydgen struct {
-	Type  string
-	Bits  uint16
-	Curve uint16
+	Type    string
+	Bits    uint16
+	Curve   uint16
+	Comment string
 
 	privateKey interface{}
 }
 
+// contextReader fails every read once ctx is done
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
 func (k *Keydgen) generateDSA(rand io.Reader) (interface{}, error) {
 
 	var (
@@ -98,11 +122,27 @@ func (k *Keydgen) generateECDSA(rand io.Reader) (interface{}, error) {
 
 // GenerateKey generates and/or returns a private key
 func (k *Keydgen) GenerateKey(rand io.Reader) (key interface{}, err error) {
+	return k.GenerateKeyContext(context.Background(), rand)
+}
+
+// GenerateKeyContext generates and/or returns a private key like
+// GenerateKey, giving up with the error of ctx once it is done
+func (k *Keydgen) GenerateKeyContext(ctx context.Context, rand io.Reader) (key interface{}, err error) {
 
 	if k.privateKey != nil {
 		return k.privateKey, nil
 	}
 
+	rand = &contextReader{ctx: ctx, r: rand}
+	defer func() {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			err = ctxErr
+		}
+		if err != nil {
+			k.privateKey, key = nil, nil
+		}
+	}()
+
 	switch k.Type {
 	case DSA:
 		k.privateKey, err = k.generateDSA(rand)
@@ -112,6 +152,14 @@ func (k *Keydgen) GenerateKey(rand io.Reader) (key interface{}, err error) {
 		k.privateKey, err = rsa.GenerateKey(rand, int(k.Bits))
 	case ED25519:
 		_, k.privateKey, err = ed25519.GenerateKey(rand)
+	case AGE:
+		k.privateKey, err = generateX25519(rand)
+	case WIREGUARD:
+		var x *x25519Key
+		if x, err = generateX25519(rand); err == nil {
+			x.clamp()
+			k.privateKey = x
+		}
 	default:
 		return nil, ErrUnsupportedKeyType
 	}
@@ -120,13 +168,21 @@ func (k *Keydgen) GenerateKey(rand io.Reader) (key interface{}, err error) {
 
 }
 
-// MarshalPrivateKey returns an OpenSSH formatted private key
+// MarshalPrivateKey returns an OpenSSH formatted private key, the identity
+// file of an age key or the base64 private key of a WireGuard key
 func (k *Keydgen) MarshalPrivateKey() ([]byte, error) {
 
 	if k.privateKey == nil {
 		panic("private key not hasn't been generated yet")
 	}
 
+	switch k.Type {
+	case AGE:
+		return k.MarshalAgeIdentity()
+	case WIREGUARD:
+		return k.MarshalWireGuardPrivateKey()
+	}
+
 	var (
 		block *pem.Block
 		buf   = bytes.NewBuffer(nil)
@@ -177,41 +233,115 @@ func (k *Keydgen) MarshalPrivateKey() ([]byte, error) {
 
 }
 
-// MarshalPublicKey returns an OpenSSH formatted public key
-func (k *Keydgen) MarshalPublicKey() ([]byte, error) {
+// PublicKey returns the OpenSSH public key of the generated private key
+func (k *Keydgen) PublicKey() (ssh.PublicKey, error) {
 
 	if k.privateKey == nil {
 		panic("private key has not been generated yet")
 	}
 
-	var (
-		pubKey ssh.PublicKey
-		err    error
-	)
-
 	switch k.Type {
 
 	case DSA:
-		var pub = &k.privateKey.(*dsa.PrivateKey).PublicKey
-		pubKey, err = ssh.NewPublicKey(pub)
+		return ssh.NewPublicKey(&k.privateKey.(*dsa.PrivateKey).PublicKey)
 
 	case ECDSA:
-		var pub = &k.privateKey.(*ecdsa.PrivateKey).PublicKey
-		pubKey, err = ssh.NewPublicKey(pub)
+		return ssh.NewPublicKey(&k.privateKey.(*ecdsa.PrivateKey).PublicKey)
 
 	case RSA:
-		var pub = &k.privateKey.(*rsa.PrivateKey).PublicKey
-		pubKey, err = ssh.NewPublicKey(pub)
+		return ssh.NewPublicKey(&k.privateKey.(*rsa.PrivateKey).PublicKey)
 
 	case ED25519:
-		var pub = k.privateKey.(ed25519.PrivateKey).Public().(ed25519.PublicKey)
-		pubKey, err = ssh.NewPublicKey(pub)
+		return ssh.NewPublicKey(k.privateKey.(ed25519.PrivateKey).Public().(ed25519.PublicKey))
 
 	default:
-		err = ErrUnsupportedKeyType
+		return nil, ErrUnsupportedKeyType
+
+	}
+
+}
+
+// MarshalPublicKey returns an OpenSSH formatted public key, the recipient
+// of an age key or the base64 public key of a WireGuard key
+func (k *Keydgen) MarshalPublicKey() ([]byte, error) {
+
+	if !SSHKeyType(k.Type) {
+		public, err := k.Fingerprint()
+		if err != nil {
+			return nil, err
+		}
+		return []byte(public + "\n"), nil
+	}
+
+	pubKey, err := k.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	authorizedKey := ssh.MarshalAuthorizedKey(pubKey)
+	if k.Comment != "" {
+		authorizedKey = append(bytes.TrimSuffix(authorizedKey, []byte("\n")), []byte(" "+k.Comment+"\n")...)
+	}
+
+	return authorizedKey, nil
+
+}
+
+// Fingerprint returns the SHA256 fingerprint of the public key, as printed
+// by ssh-keygen -l, or the recipient of an age key or public key of a
+// WireGuard key, which are short enough to compare in full
+func (k *Keydgen) Fingerprint() (string, error) {
+
+	switch k.Type {
+	case AGE:
+		return k.AgeRecipient()
+	case WIREGUARD:
+		return k.WireGuardPublicKey()
+	}
+
+	pubKey, err := k.PublicKey()
+	if err != nil {
+		return "", err
+	}
+
+	return ssh.FingerprintSHA256(pubKey), nil
+
+}
+
+// Wipe zeroes the private key held by the generator and forgets it, after
+// which a new key has to be generated
+func (k *Keydgen) Wipe() {
+
+	switch key := k.privateKey.(type) {
+
+	case *dsa.PrivateKey:
+		secret.WipeInt(key.X)
+
+	case *ecdsa.PrivateKey:
+		secret.WipeInt(key.D)
+
+	case *rsa.PrivateKey:
+		secret.WipeInt(key.D)
+		for _, prime := range key.Primes {
+			secret.WipeInt(prime)
+		}
+		secret.WipeInt(key.Precomputed.Dp)
+		secret.WipeInt(key.Precomputed.Dq)
+		secret.WipeInt(key.Precomputed.Qinv)
+		for _, crt := range key.Precomputed.CRTValues {
+			secret.WipeInt(crt.Exp)
+			secret.WipeInt(crt.Coeff)
+			secret.WipeInt(crt.R)
+		}
+
+	case ed25519.PrivateKey:
+		secret.Wipe(key)
+
+	case *x25519Key:
+		secret.Wipe(key[:])
 
 	}
 
-	return ssh.MarshalAuthorizedKey(pubKey), err
+	k.privateKey = nil
 
 }
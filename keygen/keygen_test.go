@@ -0,0 +1,43 @@
+package keygen
+
+import (
+	"context"
+	"crypto/rand"
+	"io"
+	"testing"
+)
+
+// cancellingReader cancels its context after the first read
+type cancellingReader struct {
+	cancel context.CancelFunc
+	reads  int
+}
+
+func (c *cancellingReader) Read(p []byte) (int, error) {
+	c.reads++
+	c.cancel()
+	return io.ReadFull(rand.Reader, p)
+}
+
+func TestGenerateKeyContext(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &cancellingReader{cancel: cancel}
+
+	k := &Keydgen{Type: RSA, Bits: 1024}
+	if key, err := k.GenerateKeyContext(ctx, r); err != context.Canceled || key != nil {
+		t.Fatalf("expected context.Canceled and no key, got %v", err)
+	}
+	if r.reads != 1 {
+		t.Fatalf("expected generation to stop after the first read, got %d reads", r.reads)
+	}
+
+	// a failed generation leaves nothing behind, so the next one starts over
+	if _, err := k.GenerateKeyContext(context.Background(), rand.Reader); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := k.PublicKey(); err != nil {
+		t.Fatal(err)
+	}
+
+}
@@ -0,0 +1,436 @@
+package keygen
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/cornfeedhobo/ssh-keydgen/secret"
+	"golang.org/x/crypto/ed25519"
+)
+
+// OpenPGP packet tags, algorithms and signature types of RFC 4880 and the
+// EdDSA and Curve25519 extensions used by GnuPG
+const (
+	pgpTagSignature    = 2
+	pgpTagSecretKey    = 5
+	pgpTagPublicKey    = 6
+	pgpTagSecretSubkey = 7
+	pgpTagUserID       = 13
+	pgpTagPublicSubkey = 14
+
+	pgpAlgoRSA   = 1
+	pgpAlgoECDH  = 18
+	pgpAlgoEdDSA = 22
+
+	pgpHashSHA256 = 8
+
+	pgpSigPositiveCertification = 0x13
+	pgpSigSubkeyBinding         = 0x18
+)
+
+var (
+	pgpOIDEd25519    = []byte{0x2b, 0x06, 0x01, 0x04, 0x01, 0xda, 0x47, 0x0f, 0x01}
+	pgpOIDCurve25519 = []byte{0x2b, 0x06, 0x01, 0x04, 0x01, 0x97, 0x55, 0x01, 0x05, 0x01}
+
+	// ErrMissingUserID is the error returned when an OpenPGP key is requested without a user ID
+	ErrMissingUserID = errors.New("an OpenPGP key needs a user ID")
+)
+
+// OpenPGPOptions describes the OpenPGP key wrapped around a generated key.
+// The creation time is part of the fingerprint, so it must be fixed for the
+// key to be reproduced.
+type OpenPGPOptions struct {
+	UserID  string
+	Created time.Time
+	// Encryption is an optional age or wireguard key added as a Curve25519
+	// encryption subkey
+	Encryption *Keydgen
+}
+
+// pgpKey holds the packet bodies of a key or subkey
+type pgpKey struct {
+	public, secret []byte
+}
+
+// MarshalOpenPGP returns the key as armored RFC 4880 transferable secret
+// and public keys, certified for signing under the user ID. Only Ed25519
+// and RSA keys are supported, and both sign deterministically, so the same
+// key and options always give the same output.
+func (k *Keydgen) MarshalOpenPGP(o *OpenPGPOptions) (priv, pub []byte, err error) {
+
+	if k.privateKey == nil {
+		panic("private key has not been generated yet")
+	}
+
+	if o.UserID == "" {
+		return nil, nil, ErrMissingUserID
+	}
+
+	created := uint32(o.Created.Unix())
+
+	primary, err := k.pgpKey(created)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer secret.Wipe(primary.secret)
+
+	fingerprint := pgpFingerprint(primary.public)
+
+	certification, err := k.pgpSign(pgpSigPositiveCertification, created, fingerprint, [][]byte{
+		pgpHashedKey(primary.public),
+		pgpHashedUserID(o.UserID),
+	}, []byte{0x03})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	secrets := bytes.NewBuffer(nil)
+	publics := bytes.NewBuffer(nil)
+
+	pgpWritePacket(secrets, pgpTagSecretKey, primary.secret)
+	pgpWritePacket(publics, pgpTagPublicKey, primary.public)
+	for _, w := range []*bytes.Buffer{secrets, publics} {
+		pgpWritePacket(w, pgpTagUserID, []byte(o.UserID))
+		pgpWritePacket(w, pgpTagSignature, certification)
+	}
+
+	if o.Encryption != nil {
+
+		subkey, err := o.Encryption.pgpEncryptionKey(created)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer secret.Wipe(subkey.secret)
+
+		binding, err := k.pgpSign(pgpSigSubkeyBinding, created, fingerprint, [][]byte{
+			pgpHashedKey(primary.public),
+			pgpHashedKey(subkey.public),
+		}, []byte{0x0c})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		pgpWritePacket(secrets, pgpTagSecretSubkey, subkey.secret)
+		pgpWritePacket(publics, pgpTagPublicSubkey, subkey.public)
+		for _, w := range []*bytes.Buffer{secrets, publics} {
+			pgpWritePacket(w, pgpTagSignature, binding)
+		}
+
+	}
+
+	defer secret.Wipe(secrets.Bytes())
+
+	return pgpArmor("PGP PRIVATE KEY BLOCK", secrets.Bytes()), pgpArmor("PGP PUBLIC KEY BLOCK", publics.Bytes()), nil
+
+}
+
+// OpenPGPFingerprint returns the fingerprint of the OpenPGP primary key
+// created at created, as printed by gpg
+func (k *Keydgen) OpenPGPFingerprint(created time.Time) (string, error) {
+
+	if k.privateKey == nil {
+		panic("private key has not been generated yet")
+	}
+
+	primary, err := k.pgpKey(uint32(created.Unix()))
+	if err != nil {
+		return "", err
+	}
+	secret.Wipe(primary.secret)
+
+	return strings.ToUpper(hex.EncodeToString(pgpFingerprint(primary.public))), nil
+
+}
+
+// pgpKey returns the version 4 key packet bodies of the primary key
+func (k *Keydgen) pgpKey(created uint32) (*pgpKey, error) {
+
+	public := bytes.NewBuffer(nil)
+	public.WriteByte(4)
+	binary.Write(public, binary.BigEndian, created)
+
+	var secretMPIs [][]byte
+
+	switch key := k.privateKey.(type) {
+
+	case *rsa.PrivateKey:
+		if len(key.Primes) != 2 {
+			return nil, ErrUnsupportedFormat
+		}
+		// OpenPGP wants p < q and u = p^-1 mod q
+		p, q := key.Primes[0], key.Primes[1]
+		if p.Cmp(q) > 0 {
+			p, q = q, p
+		}
+		public.WriteByte(pgpAlgoRSA)
+		public.Write(pgpMPI(key.N.Bytes()))
+		public.Write(pgpMPI(big.NewInt(int64(key.E)).Bytes()))
+		secretMPIs = [][]byte{key.D.Bytes(), p.Bytes(), q.Bytes(), new(big.Int).ModInverse(p, q).Bytes()}
+
+	case ed25519.PrivateKey:
+		public.WriteByte(pgpAlgoEdDSA)
+		public.WriteByte(byte(len(pgpOIDEd25519)))
+		public.Write(pgpOIDEd25519)
+		public.Write(pgpMPI(append([]byte{0x40}, key[ed25519.SeedSize:]...)))
+		secretMPIs = [][]byte{append([]byte(nil), key[:ed25519.SeedSize]...)}
+
+	default:
+		return nil, ErrUnsupportedFormat
+
+	}
+
+	return newPGPKey(public.Bytes(), secretMPIs), nil
+
+}
+
+// pgpEncryptionKey returns the version 4 key packet bodies of a Curve25519
+// encryption subkey
+func (k *Keydgen) pgpEncryptionKey(created uint32) (*pgpKey, error) {
+
+	if k.privateKey == nil {
+		panic("private key has not been generated yet")
+	}
+
+	key, ok := k.privateKey.(*x25519Key)
+	if !ok {
+		return nil, ErrUnsupportedKeyType
+	}
+
+	public := bytes.NewBuffer(nil)
+	public.WriteByte(4)
+	binary.Write(public, binary.BigEndian, created)
+	public.WriteByte(pgpAlgoECDH)
+	public.WriteByte(byte(len(pgpOIDCurve25519)))
+	public.Write(pgpOIDCurve25519)
+	public.Write(pgpMPI(append([]byte{0x40}, key.public()...)))
+	// KDF parameters: SHA-256 and AES-128
+	public.Write([]byte{3, 1, pgpHashSHA256, 7})
+
+	// the clamped scalar, stored big-endian unlike X25519
+	clamped := *key
+	clamped.clamp()
+	scalar := make([]byte, len(clamped))
+	for i := range clamped {
+		scalar[i] = clamped[len(clamped)-1-i]
+	}
+	secret.Wipe(clamped[:])
+
+	return newPGPKey(public.Bytes(), [][]byte{scalar}), nil
+
+}
+
+// newPGPKey returns the public body and the unencrypted secret body made of
+// it, the secret MPIs and their checksum, wiping the MPIs
+func newPGPKey(public []byte, secretMPIs [][]byte) *pgpKey {
+
+	buf := bytes.NewBuffer(make([]byte, 0, len(public)+1024))
+	buf.Write(public)
+	buf.WriteByte(0)
+
+	var checksum uint16
+	for _, m := range secretMPIs {
+		encoded := pgpMPI(m)
+		for _, b := range encoded {
+			checksum += uint16(b)
+		}
+		buf.Write(encoded)
+		secret.Wipe(encoded)
+		secret.Wipe(m)
+	}
+	binary.Write(buf, binary.BigEndian, checksum)
+
+	return &pgpKey{public: public, secret: buf.Bytes()}
+
+}
+
+// pgpSign returns the body of a version 4 signature by the primary key over
+// hashed, with the signature creation time set to created so it is
+// reproducible
+func (k *Keydgen) pgpSign(sigType byte, created uint32, fingerprint []byte, hashed [][]byte, flags []byte) ([]byte, error) {
+
+	var algo byte
+	switch k.privateKey.(type) {
+	case *rsa.PrivateKey:
+		algo = pgpAlgoRSA
+	case ed25519.PrivateKey:
+		algo = pgpAlgoEdDSA
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+
+	subpackets := bytes.NewBuffer(nil)
+	pgpWriteSubpacket(subpackets, 33, append([]byte{4}, fingerprint...))
+	pgpWriteSubpacket(subpackets, 2, []byte{byte(created >> 24), byte(created >> 16), byte(created >> 8), byte(created)})
+	pgpWriteSubpacket(subpackets, 27, flags)
+	if sigType == pgpSigPositiveCertification {
+		// preferred ciphers, hashes and compression, features and key server preferences
+		pgpWriteSubpacket(subpackets, 11, []byte{9, 8, 7})
+		pgpWriteSubpacket(subpackets, 21, []byte{8, 10, 9})
+		pgpWriteSubpacket(subpackets, 22, []byte{2, 3, 1})
+		pgpWriteSubpacket(subpackets, 30, []byte{1})
+		pgpWriteSubpacket(subpackets, 23, []byte{0x80})
+	}
+
+	signed := bytes.NewBuffer(nil)
+	signed.Write([]byte{4, sigType, algo, pgpHashSHA256})
+	binary.Write(signed, binary.BigEndian, uint16(subpackets.Len()))
+	signed.Write(subpackets.Bytes())
+
+	h := sha256.New()
+	for _, b := range hashed {
+		h.Write(b)
+	}
+	h.Write(signed.Bytes())
+	h.Write([]byte{4, 0xff})
+	binary.Write(h, binary.BigEndian, uint32(signed.Len()))
+	digest := h.Sum(nil)
+
+	var mpis [][]byte
+	switch key := k.privateKey.(type) {
+
+	case *rsa.PrivateKey:
+		signature, err := rsa.SignPKCS1v15(nil, key, crypto.SHA256, digest)
+		if err != nil {
+			return nil, err
+		}
+		mpis = [][]byte{signature}
+
+	case ed25519.PrivateKey:
+		// EdDSA signs the digest rather than the data
+		signature := ed25519.Sign(key, digest)
+		mpis = [][]byte{signature[:32], signature[32:]}
+
+	}
+
+	body := bytes.NewBuffer(nil)
+	body.Write(signed.Bytes())
+	unhashed := bytes.NewBuffer(nil)
+	pgpWriteSubpacket(unhashed, 16, fingerprint[len(fingerprint)-8:])
+	binary.Write(body, binary.BigEndian, uint16(unhashed.Len()))
+	body.Write(unhashed.Bytes())
+	body.Write(digest[:2])
+	for _, m := range mpis {
+		body.Write(pgpMPI(m))
+	}
+
+	return body.Bytes(), nil
+
+}
+
+// pgpFingerprint returns the version 4 fingerprint of a public key body
+func pgpFingerprint(public []byte) []byte {
+	sum := sha1.Sum(pgpHashedKey(public))
+	return sum[:]
+}
+
+// pgpHashedKey returns a public key body as it is hashed for fingerprints and signatures
+func pgpHashedKey(public []byte) []byte {
+	return append([]byte{0x99, byte(len(public) >> 8), byte(len(public))}, public...)
+}
+
+// pgpHashedUserID returns a user ID as it is hashed for certifications
+func pgpHashedUserID(uid string) []byte {
+	b := []byte{0xb4, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(b[1:], uint32(len(uid)))
+	return append(b, uid...)
+}
+
+// pgpMPI returns b as a multiprecision integer, without leading zeroes
+func pgpMPI(b []byte) []byte {
+
+	for len(b) > 0 && b[0] == 0 {
+		b = b[1:]
+	}
+
+	bits := len(b) * 8
+	if len(b) > 0 {
+		for top := b[0]; top&0x80 == 0; top <<= 1 {
+			bits--
+		}
+	}
+
+	return append([]byte{byte(bits >> 8), byte(bits)}, b...)
+
+}
+
+// pgpWritePacket writes a packet with a new format header
+func pgpWritePacket(w *bytes.Buffer, tag byte, body []byte) {
+
+	w.WriteByte(0xc0 | tag)
+
+	switch n := len(body); {
+	case n < 192:
+		w.WriteByte(byte(n))
+	case n < 8384:
+		n -= 192
+		w.Write([]byte{byte(n>>8) + 192, byte(n)})
+	default:
+		w.WriteByte(0xff)
+		binary.Write(w, binary.BigEndian, uint32(n))
+	}
+
+	w.Write(body)
+
+}
+
+// pgpWriteSubpacket writes a signature subpacket, all of which are short
+func pgpWriteSubpacket(w *bytes.Buffer, kind byte, data []byte) {
+	w.Write([]byte{byte(len(data) + 1), kind})
+	w.Write(data)
+}
+
+// pgpArmor returns data in ASCII armor with its CRC-24 checksum
+func pgpArmor(blockType string, data []byte) []byte {
+
+	crc := pgpCRC24(data)
+
+	encoded := make([]byte, base64.StdEncoding.EncodedLen(len(data)))
+	base64.StdEncoding.Encode(encoded, data)
+	defer secret.Wipe(encoded)
+
+	buf := bytes.NewBuffer(make([]byte, 0, len(encoded)+len(encoded)/64+128))
+	fmt.Fprintf(buf, "-----BEGIN %s-----\n\n", blockType)
+	for len(encoded) > 0 {
+		n := 64
+		if n > len(encoded) {
+			n = len(encoded)
+		}
+		buf.Write(encoded[:n])
+		buf.WriteByte('\n')
+		encoded = encoded[n:]
+	}
+	fmt.Fprintf(buf, "=%s\n", base64.StdEncoding.EncodeToString([]byte{byte(crc >> 16), byte(crc >> 8), byte(crc)}))
+	fmt.Fprintf(buf, "-----END %s-----\n", blockType)
+
+	return buf.Bytes()
+
+}
+
+// pgpCRC24 returns the CRC-24 checksum of RFC 4880 section 6.1
+func pgpCRC24(data []byte) uint32 {
+
+	crc := uint32(0xb704ce)
+	for _, b := range data {
+		crc ^= uint32(b) << 16
+		for i := 0; i < 8; i++ {
+			crc <<= 1
+			if crc&0x1000000 != 0 {
+				crc ^= 0x1864cfb
+			}
+		}
+	}
+
+	return crc & 0xffffff
+
+}
@@ -0,0 +1,137 @@
+package keygen
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+var (
+	oidEd25519 = asn1.ObjectIdentifier{1, 3, 101, 112}
+
+	// ErrUnsupportedFormat is the error returned when a key type can not be encoded in the requested format
+	ErrUnsupportedFormat = errors.New("key type is not supported by this format")
+)
+
+// pkcs8 is the PrivateKeyInfo structure of RFC 5208
+type pkcs8 struct {
+	Version    int
+	Algo       pkix.AlgorithmIdentifier
+	PrivateKey []byte
+}
+
+// subjectPublicKeyInfo is the structure of RFC 5280
+type subjectPublicKeyInfo struct {
+	Algo      pkix.AlgorithmIdentifier
+	PublicKey asn1.BitString
+}
+
+// MarshalPKCS8PrivateKey returns the private key as a PKCS #8 "PRIVATE KEY"
+// PEM block, as read by TLS tools and most languages. DSA keys are not supported.
+func (k *Keydgen) MarshalPKCS8PrivateKey() ([]byte, error) {
+
+	if k.privateKey == nil {
+		panic("private key has not been generated yet")
+	}
+
+	var (
+		der []byte
+		err error
+	)
+
+	switch key := k.privateKey.(type) {
+
+	case *rsa.PrivateKey, *ecdsa.PrivateKey:
+		der, err = x509.MarshalPKCS8PrivateKey(key)
+
+	case ed25519.PrivateKey:
+		// the vendored ed25519 type is unknown to crypto/x509, so RFC 8410
+		// is followed by hand
+		info := pkcs8{Algo: pkix.AlgorithmIdentifier{Algorithm: oidEd25519}}
+		if info.PrivateKey, err = asn1.Marshal(key[:ed25519.SeedSize]); err == nil {
+			der, err = asn1.Marshal(info)
+		}
+
+	default:
+		return nil, ErrUnsupportedFormat
+
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+
+}
+
+// MarshalPKIXPublicKey returns the public key as a SubjectPublicKeyInfo
+// "PUBLIC KEY" PEM block. DSA keys are not supported.
+func (k *Keydgen) MarshalPKIXPublicKey() ([]byte, error) {
+
+	der, err := k.marshalPublicKeyInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+
+}
+
+// publicKeyInfo returns the SubjectPublicKeyInfo of the key, for embedding
+// in certificates
+func (k *Keydgen) publicKeyInfo() (subjectPublicKeyInfo, error) {
+
+	var info subjectPublicKeyInfo
+
+	der, err := k.marshalPublicKeyInfo()
+	if err != nil {
+		return info, err
+	}
+
+	_, err = asn1.Unmarshal(der, &info)
+
+	return info, err
+
+}
+
+func (k *Keydgen) marshalPublicKeyInfo() ([]byte, error) {
+
+	if k.privateKey == nil {
+		panic("private key has not been generated yet")
+	}
+
+	switch key := k.privateKey.(type) {
+
+	case *rsa.PrivateKey:
+		return x509.MarshalPKIXPublicKey(&key.PublicKey)
+
+	case *ecdsa.PrivateKey:
+		return x509.MarshalPKIXPublicKey(&key.PublicKey)
+
+	case ed25519.PrivateKey:
+		pub := key.Public().(ed25519.PublicKey)
+		return asn1.Marshal(subjectPublicKeyInfo{
+			Algo:      pkix.AlgorithmIdentifier{Algorithm: oidEd25519},
+			PublicKey: asn1.BitString{Bytes: pub, BitLength: len(pub) * 8},
+		})
+
+	default:
+		return nil, ErrUnsupportedFormat
+
+	}
+
+}
+
+func leftPad(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b
+	}
+	return append(make([]byte, size-len(b)), b...)
+}
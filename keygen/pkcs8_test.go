@@ -0,0 +1,114 @@
+package keygen
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestMarshalPKCS8(t *testing.T) {
+
+	cases := []*Keydgen{
+		{Type: RSA, Bits: 1024},
+		{Type: ECDSA, Curve: 256},
+		{Type: ECDSA, Curve: 384},
+		{Type: ECDSA, Curve: 521},
+	}
+
+	for _, k := range cases {
+
+		key, err := k.GenerateKey(rand.New(rand.NewSource(1)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		der := decodePEM(t, k.MarshalPKCS8PrivateKey, "PRIVATE KEY")
+		parsed, err := x509.ParsePKCS8PrivateKey(der)
+		if err != nil {
+			t.Fatalf("%s: %v", k.Type, err)
+		}
+		if !reflect.DeepEqual(publicOf(parsed), publicOf(key)) {
+			t.Fatalf("%s: private key does not round trip", k.Type)
+		}
+
+		der = decodePEM(t, k.MarshalPKIXPublicKey, "PUBLIC KEY")
+		pub, err := x509.ParsePKIXPublicKey(der)
+		if err != nil {
+			t.Fatalf("%s: %v", k.Type, err)
+		}
+		if !reflect.DeepEqual(pub, publicOf(key)) {
+			t.Fatalf("%s: public key does not round trip", k.Type)
+		}
+
+	}
+
+}
+
+func TestMarshalPKCS8Ed25519(t *testing.T) {
+
+	k := &Keydgen{Type: ED25519}
+	key, err := k.GenerateKey(rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv := key.(ed25519.PrivateKey)
+
+	// RFC 8410 section 7 and 4
+	expected, _ := hex.DecodeString("302e020100300506032b657004220420")
+	if der := decodePEM(t, k.MarshalPKCS8PrivateKey, "PRIVATE KEY"); !bytes.Equal(der, append(expected, priv[:32]...)) {
+		t.Fatalf("unexpected private key encoding %x", der)
+	}
+
+	expected, _ = hex.DecodeString("302a300506032b6570032100")
+	if der := decodePEM(t, k.MarshalPKIXPublicKey, "PUBLIC KEY"); !bytes.Equal(der, append(expected, priv[32:]...)) {
+		t.Fatalf("unexpected public key encoding %x", der)
+	}
+
+}
+
+func TestMarshalPKCS8DSA(t *testing.T) {
+
+	k := &Keydgen{Type: DSA, Bits: 1024}
+	if _, err := k.GenerateKey(rand.New(rand.NewSource(1))); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := k.MarshalPKCS8PrivateKey(); err != ErrUnsupportedFormat {
+		t.Fatalf("expected ErrUnsupportedFormat, got %v", err)
+	}
+
+}
+
+func decodePEM(t *testing.T, marshal func() ([]byte, error), blockType string) []byte {
+
+	b, err := marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, _ := pem.Decode(b)
+	if block == nil || block.Type != blockType {
+		t.Fatalf("expected a %s PEM block, got %q", blockType, b)
+	}
+
+	return block.Bytes
+
+}
+
+func publicOf(key interface{}) interface{} {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return &k.PublicKey
+	case *ecdsa.PrivateKey:
+		return &k.PublicKey
+	}
+	return nil
+}
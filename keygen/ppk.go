@@ -0,0 +1,201 @@
+package keygen
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/dsa"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math/big"
+
+	"github.com/cornfeedhobo/ssh-keydgen/secret"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/ssh"
+)
+
+// These constants represent the supported PuTTY private key file versions
+const (
+	PPKv2 = 2
+	PPKv3 = 3
+)
+
+// Argon2id parameters protecting encrypted PPK v3 files, puttygen's default
+// memory and parallelism with a fixed number of passes
+const (
+	ppkArgon2Memory      = 8192
+	ppkArgon2Passes      = 13
+	ppkArgon2Parallelism = 1
+)
+
+// ErrUnsupportedPPKVersion is the error returned when a PPK version other than 2 or 3 is requested
+var ErrUnsupportedPPKVersion = errors.New("only PPK versions 2 and 3 are supported")
+
+// MarshalPPK returns the key as a PuTTY private key file of the given
+// version. With a passphrase, the private key is encrypted with
+// AES-256-CBC and rand supplies the Argon2 salt of version 3 files.
+func (k *Keydgen) MarshalPPK(version int, passphrase []byte, rand io.Reader) ([]byte, error) {
+
+	if version != PPKv2 && version != PPKv3 {
+		return nil, ErrUnsupportedPPKVersion
+	}
+
+	pubKey, err := k.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	private, err := k.ppkPrivateBlob()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { secret.Wipe(private) }()
+
+	var (
+		algorithm  = pubKey.Type()
+		public     = pubKey.Marshal()
+		encryption = "none"
+		header     = bytes.NewBuffer(nil)
+		macKey     []byte
+		newMAC     func() hash.Hash
+		block      cipher.BlockMode
+	)
+
+	if len(passphrase) > 0 {
+		encryption = "aes256-cbc"
+		// pad with part of a hash of the private key, like PuTTY, rather than
+		// with known plaintext
+		if padding := aes.BlockSize - len(private)%aes.BlockSize; padding != aes.BlockSize {
+			sum := sha1.Sum(private)
+			private = append(private, sum[:padding]...)
+		}
+	}
+
+	switch version {
+
+	case PPKv2:
+		newMAC = sha1.New
+		mac := sha1.Sum(append([]byte("putty-private-key-file-mac-key"), passphrase...))
+		macKey = mac[:]
+		if len(passphrase) > 0 {
+			first := sha1.Sum(append([]byte{0, 0, 0, 0}, passphrase...))
+			second := sha1.Sum(append([]byte{0, 0, 0, 1}, passphrase...))
+			c, err := aes.NewCipher(append(first[:], second[:12]...))
+			if err != nil {
+				return nil, err
+			}
+			block = cipher.NewCBCEncrypter(c, make([]byte, aes.BlockSize))
+		}
+
+	case PPKv3:
+		newMAC = sha256.New
+		if len(passphrase) > 0 {
+			salt := make([]byte, 16)
+			if _, err = io.ReadFull(rand, salt); err != nil {
+				return nil, err
+			}
+			derived := argon2.IDKey(passphrase, salt, ppkArgon2Passes, ppkArgon2Memory, ppkArgon2Parallelism, 80)
+			c, err := aes.NewCipher(derived[:32])
+			if err != nil {
+				return nil, err
+			}
+			block = cipher.NewCBCEncrypter(c, derived[32:48])
+			macKey = derived[48:]
+			fmt.Fprintf(header, "Key-Derivation: Argon2id\nArgon2-Memory: %d\nArgon2-Passes: %d\nArgon2-Parallelism: %d\nArgon2-Salt: %x\n",
+				ppkArgon2Memory, ppkArgon2Passes, ppkArgon2Parallelism, salt)
+		}
+
+	}
+
+	macData := bytes.NewBuffer(nil)
+	for _, field := range [][]byte{[]byte(algorithm), []byte(encryption), []byte(k.Comment), public, private} {
+		binary.Write(macData, binary.BigEndian, uint32(len(field)))
+		macData.Write(field)
+	}
+	mac := hmac.New(newMAC, macKey)
+	mac.Write(macData.Bytes())
+	secret.Wipe(macData.Bytes())
+
+	if block != nil {
+		block.CryptBlocks(private, private)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	fmt.Fprintf(buf, "PuTTY-User-Key-File-%d: %s\n", version, algorithm)
+	fmt.Fprintf(buf, "Encryption: %s\n", encryption)
+	fmt.Fprintf(buf, "Comment: %s\n", k.Comment)
+	writePPKLines(buf, "Public", public)
+	buf.Write(header.Bytes())
+	writePPKLines(buf, "Private", private)
+	fmt.Fprintf(buf, "Private-MAC: %s\n", hex.EncodeToString(mac.Sum(nil)))
+
+	return buf.Bytes(), nil
+
+}
+
+// ppkPrivateBlob returns the private fields of the key in the order PuTTY stores them
+func (k *Keydgen) ppkPrivateBlob() ([]byte, error) {
+
+	if k.privateKey == nil {
+		panic("private key has not been generated yet")
+	}
+
+	switch key := k.privateKey.(type) {
+
+	case *rsa.PrivateKey:
+		if len(key.Primes) != 2 {
+			return nil, ErrUnsupportedFormat
+		}
+		p, q := key.Primes[0], key.Primes[1]
+		return ssh.Marshal(struct {
+			D, P, Q, Iqmp *big.Int
+		}{key.D, p, q, new(big.Int).ModInverse(q, p)}), nil
+
+	case *dsa.PrivateKey:
+		return ssh.Marshal(struct{ X *big.Int }{key.X}), nil
+
+	case *ecdsa.PrivateKey:
+		return ssh.Marshal(struct{ D *big.Int }{key.D}), nil
+
+	case ed25519.PrivateKey:
+		// the seed as an unsigned little-endian integer of minimal length
+		seed := key[:ed25519.SeedSize]
+		for len(seed) > 0 && seed[len(seed)-1] == 0 {
+			seed = seed[:len(seed)-1]
+		}
+		return ssh.Marshal(struct{ Seed []byte }{seed}), nil
+
+	default:
+		return nil, ErrUnsupportedKeyType
+
+	}
+
+}
+
+// writePPKLines writes a PPK section of base64 encoded data, 64 characters per line
+func writePPKLines(w io.Writer, name string, data []byte) {
+
+	encoded := base64.StdEncoding.EncodeToString(data)
+	lines := (len(encoded) + 63) / 64
+
+	fmt.Fprintf(w, "%s-Lines: %d\n", name, lines)
+	for i := 0; i < len(encoded); i += 64 {
+		end := i + 64
+		if end > len(encoded) {
+			end = len(encoded)
+		}
+		fmt.Fprintln(w, encoded[i:end])
+	}
+
+}
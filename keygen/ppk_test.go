@@ -0,0 +1,150 @@
+package keygen
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+)
+
+func TestMarshalPPK(t *testing.T) {
+
+	cases := []*Keydgen{
+		{Type: ED25519, Comment: "ed25519 key"},
+		{Type: ECDSA, Curve: 384},
+		{Type: RSA, Bits: 1024},
+		{Type: DSA, Bits: 1024},
+	}
+
+	for _, k := range cases {
+
+		if _, err := k.GenerateKey(rand.New(rand.NewSource(1))); err != nil {
+			t.Fatal(err)
+		}
+
+		expected, err := k.ppkPrivateBlob()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, version := range []int{PPKv2, PPKv3} {
+			for _, passphrase := range []string{"", "hunter2"} {
+
+				b, err := k.MarshalPPK(version, []byte(passphrase), rand.New(rand.NewSource(2)))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				private := readPPK(t, b, version, passphrase)
+				if !bytes.HasPrefix(private, expected) {
+					t.Fatalf("%s v%d %q: private key does not round trip", k.Type, version, passphrase)
+				}
+
+			}
+		}
+
+	}
+
+	if _, err := cases[0].MarshalPPK(1, nil, nil); err != ErrUnsupportedPPKVersion {
+		t.Fatalf("expected ErrUnsupportedPPKVersion, got %v", err)
+	}
+
+}
+
+// readPPK decrypts a PPK file and verifies its MAC, returning the private blob
+func readPPK(t *testing.T, b []byte, version int, passphrase string) []byte {
+
+	var (
+		fields  = map[string]string{}
+		blobs   = map[string][]byte{}
+		scanner = bufio.NewScanner(bytes.NewReader(b))
+	)
+
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ": ", 2)
+		if len(parts) != 2 {
+			t.Fatalf("unexpected line %q", scanner.Text())
+		}
+		if strings.HasSuffix(parts[0], "-Lines") {
+			n, _ := strconv.Atoi(parts[1])
+			var encoded string
+			for i := 0; i < n && scanner.Scan(); i++ {
+				encoded += scanner.Text()
+			}
+			blob, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			blobs[strings.TrimSuffix(parts[0], "-Lines")] = blob
+			continue
+		}
+		fields[parts[0]] = parts[1]
+	}
+
+	algorithm := fields["PuTTY-User-Key-File-"+strconv.Itoa(version)]
+	if algorithm == "" {
+		t.Fatalf("missing version %d header in %s", version, b)
+	}
+
+	var (
+		private = blobs["Private"]
+		macKey  []byte
+		newMAC  func() hash.Hash
+	)
+
+	switch version {
+
+	case PPKv2:
+		newMAC = sha1.New
+		sum := sha1.Sum([]byte("putty-private-key-file-mac-key" + passphrase))
+		macKey = sum[:]
+		if passphrase != "" {
+			first := sha1.Sum([]byte("\x00\x00\x00\x00" + passphrase))
+			second := sha1.Sum([]byte("\x00\x00\x00\x01" + passphrase))
+			c, _ := aes.NewCipher(append(first[:], second[:12]...))
+			cipher.NewCBCDecrypter(c, make([]byte, 16)).CryptBlocks(private, private)
+		}
+
+	case PPKv3:
+		newMAC = sha256.New
+		if passphrase != "" {
+			if fields["Key-Derivation"] != "Argon2id" {
+				t.Fatalf("unexpected key derivation %q", fields["Key-Derivation"])
+			}
+			memory, _ := strconv.Atoi(fields["Argon2-Memory"])
+			passes, _ := strconv.Atoi(fields["Argon2-Passes"])
+			parallelism, _ := strconv.Atoi(fields["Argon2-Parallelism"])
+			salt, _ := hex.DecodeString(fields["Argon2-Salt"])
+			derived := argon2.IDKey([]byte(passphrase), salt, uint32(passes), uint32(memory), uint8(parallelism), 80)
+			c, _ := aes.NewCipher(derived[:32])
+			cipher.NewCBCDecrypter(c, derived[32:48]).CryptBlocks(private, private)
+			macKey = derived[48:]
+		}
+
+	}
+
+	mac := hmac.New(newMAC, macKey)
+	for _, field := range [][]byte{[]byte(algorithm), []byte(fields["Encryption"]), []byte(fields["Comment"]), blobs["Public"], private} {
+		binary.Write(mac, binary.BigEndian, uint32(len(field)))
+		mac.Write(field)
+	}
+	if hex.EncodeToString(mac.Sum(nil)) != fields["Private-MAC"] {
+		t.Fatalf("MAC does not verify for\n%s", b)
+	}
+
+	return private
+
+}
@@ -0,0 +1,48 @@
+package keygen
+
+import "encoding/base64"
+
+// clamp clamps the scalar like wg genkey, so the stored private key is the
+// one WireGuard uses
+func (key *x25519Key) clamp() {
+	key[0] &= 248
+	key[31] = key[31]&127 | 64
+}
+
+// WireGuardPublicKey returns the base64 public key of a WireGuard key, as
+// printed by wg pubkey
+func (k *Keydgen) WireGuardPublicKey() (string, error) {
+
+	if k.privateKey == nil {
+		panic("private key has not been generated yet")
+	}
+
+	key, ok := k.privateKey.(*x25519Key)
+	if !ok || k.Type != WIREGUARD {
+		return "", ErrUnsupportedKeyType
+	}
+
+	return base64.StdEncoding.EncodeToString(key.public()), nil
+
+}
+
+// MarshalWireGuardPrivateKey returns the base64 private key of a WireGuard
+// key followed by a newline, as printed by wg genkey
+func (k *Keydgen) MarshalWireGuardPrivateKey() ([]byte, error) {
+
+	if k.privateKey == nil {
+		panic("private key has not been generated yet")
+	}
+
+	key, ok := k.privateKey.(*x25519Key)
+	if !ok || k.Type != WIREGUARD {
+		return nil, ErrUnsupportedKeyType
+	}
+
+	encoded := make([]byte, base64.StdEncoding.EncodedLen(len(key))+1)
+	base64.StdEncoding.Encode(encoded, key[:])
+	encoded[len(encoded)-1] = '\n'
+
+	return encoded, nil
+
+}
@@ -0,0 +1,46 @@
+package keygen
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+)
+
+// the X25519 keys of RFC 7748 section 6.1
+func TestWireGuard(t *testing.T) {
+
+	scalar, _ := hex.DecodeString("77076d0a7318a57d3c16c17251b26645df4c2f87ebc0992ab177fba51db92c2a")
+	public, _ := hex.DecodeString("8520f0098930a754748b7ddcb43ef75a0dbf3a0d26381af4eba4a98eaa9b4e6a")
+
+	k := &Keydgen{Type: WIREGUARD}
+	if _, err := k.GenerateKey(bytes.NewReader(scalar)); err != nil {
+		t.Fatal(err)
+	}
+
+	clamped := append([]byte(nil), scalar...)
+	clamped[0] &= 248
+	clamped[31] = clamped[31]&127 | 64
+
+	private, err := k.MarshalPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := base64.StdEncoding.EncodeToString(clamped) + "\n"; string(private) != expected {
+		t.Fatalf("expected %q, got %q", expected, private)
+	}
+
+	// clamping does not change the public key, X25519 clamps anyway
+	pub, err := k.MarshalPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := base64.StdEncoding.EncodeToString(public) + "\n"; string(pub) != expected {
+		t.Fatalf("expected %q, got %q", expected, pub)
+	}
+
+	if _, err = k.AgeRecipient(); err != ErrUnsupportedKeyType {
+		t.Fatalf("expected %v, got %v", ErrUnsupportedKeyType, err)
+	}
+
+}
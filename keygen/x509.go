@@ -0,0 +1,312 @@
+package keygen
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"hash"
+	"math/big"
+	"net"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+var (
+	oidSHA256WithRSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+	oidECDSAWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	oidECDSAWithSHA384 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 3}
+	oidECDSAWithSHA512 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 4}
+
+	oidSubjectKeyID     = asn1.ObjectIdentifier{2, 5, 29, 14}
+	oidKeyUsage         = asn1.ObjectIdentifier{2, 5, 29, 15}
+	oidSubjectAltName   = asn1.ObjectIdentifier{2, 5, 29, 17}
+	oidBasicConstraints = asn1.ObjectIdentifier{2, 5, 29, 19}
+	oidExtKeyUsage      = asn1.ObjectIdentifier{2, 5, 29, 37}
+
+	extKeyUsageOIDs = map[x509.ExtKeyUsage]asn1.ObjectIdentifier{
+		x509.ExtKeyUsageAny:             {2, 5, 29, 37, 0},
+		x509.ExtKeyUsageServerAuth:      {1, 3, 6, 1, 5, 5, 7, 3, 1},
+		x509.ExtKeyUsageClientAuth:      {1, 3, 6, 1, 5, 5, 7, 3, 2},
+		x509.ExtKeyUsageCodeSigning:     {1, 3, 6, 1, 5, 5, 7, 3, 3},
+		x509.ExtKeyUsageEmailProtection: {1, 3, 6, 1, 5, 5, 7, 3, 4},
+		x509.ExtKeyUsageTimeStamping:    {1, 3, 6, 1, 5, 5, 7, 3, 8},
+		x509.ExtKeyUsageOCSPSigning:     {1, 3, 6, 1, 5, 5, 7, 3, 9},
+	}
+
+	// ErrInvalidValidity is the error returned when a certificate expires before it becomes valid
+	ErrInvalidValidity = errors.New("certificate must expire after it becomes valid")
+
+	// ErrUnsupportedExtKeyUsage is the error returned when an extended key usage has no known identifier
+	ErrUnsupportedExtKeyUsage = errors.New("unsupported extended key usage")
+)
+
+// CertificateTemplate describes a self-signed certificate. Every field is
+// part of the signed certificate, so the same template and key always give
+// the same certificate, except for ECDSA keys whose signatures are random.
+type CertificateTemplate struct {
+	Subject        pkix.Name
+	DNSNames       []string
+	EmailAddresses []string
+	IPAddresses    []net.IP
+	URIs           []string
+	NotBefore      time.Time
+	NotAfter       time.Time
+	KeyUsage       x509.KeyUsage
+	ExtKeyUsage    []x509.ExtKeyUsage
+	IsCA           bool
+}
+
+// tbsCertificate is the TBSCertificate structure of RFC 5280
+type tbsCertificate struct {
+	Version            int `asn1:"optional,explicit,default:0,tag:0"`
+	SerialNumber       *big.Int
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Issuer             asn1.RawValue
+	Validity           validity
+	Subject            asn1.RawValue
+	PublicKey          subjectPublicKeyInfo
+	Extensions         []pkix.Extension `asn1:"optional,explicit,tag:3"`
+}
+
+type validity struct {
+	NotBefore, NotAfter time.Time
+}
+
+type certificate struct {
+	TBSCertificate     asn1.RawValue
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	SignatureValue     asn1.BitString
+}
+
+// MarshalCertificate returns a self-signed X.509 certificate for the key
+// as a "CERTIFICATE" PEM block. The serial number is derived from the
+// rest of the certificate. DSA keys are not supported.
+func (k *Keydgen) MarshalCertificate(t *CertificateTemplate) ([]byte, error) {
+
+	if k.privateKey == nil {
+		panic("private key has not been generated yet")
+	}
+
+	notBefore, notAfter := t.NotBefore.UTC().Truncate(time.Second), t.NotAfter.UTC().Truncate(time.Second)
+	if !notAfter.After(notBefore) {
+		return nil, ErrInvalidValidity
+	}
+
+	info, err := k.publicKeyInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	algo, newHash, err := k.signatureAlgorithm()
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := asn1.Marshal(t.Subject.ToRDNSequence())
+	if err != nil {
+		return nil, err
+	}
+
+	extensions, err := t.extensions(info)
+	if err != nil {
+		return nil, err
+	}
+
+	tbs := tbsCertificate{
+		Version:            2,
+		SerialNumber:       big.NewInt(0),
+		SignatureAlgorithm: algo,
+		Issuer:             asn1.RawValue{FullBytes: name},
+		Validity:           validity{notBefore, notAfter},
+		Subject:            asn1.RawValue{FullBytes: name},
+		PublicKey:          info,
+		Extensions:         extensions,
+	}
+
+	// a positive serial of 16 bytes, unique to everything else in the certificate
+	der, err := asn1.Marshal(tbs)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(der)
+	sum[0] = sum[0]&0x7f | 0x40
+	tbs.SerialNumber = new(big.Int).SetBytes(sum[:16])
+
+	if der, err = asn1.Marshal(tbs); err != nil {
+		return nil, err
+	}
+
+	signature, err := k.sign(der, newHash)
+	if err != nil {
+		return nil, err
+	}
+
+	der, err = asn1.Marshal(certificate{
+		TBSCertificate:     asn1.RawValue{FullBytes: der},
+		SignatureAlgorithm: algo,
+		SignatureValue:     asn1.BitString{Bytes: signature, BitLength: len(signature) * 8},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), nil
+
+}
+
+// signatureAlgorithm returns the algorithm used to sign certificates and the
+// digest it signs, nil for Ed25519 which signs the message itself
+func (k *Keydgen) signatureAlgorithm() (pkix.AlgorithmIdentifier, func() hash.Hash, error) {
+
+	switch key := k.privateKey.(type) {
+
+	case *rsa.PrivateKey:
+		return pkix.AlgorithmIdentifier{Algorithm: oidSHA256WithRSA, Parameters: asn1.NullRawValue}, sha256.New, nil
+
+	case *ecdsa.PrivateKey:
+		switch key.Curve.Params().BitSize {
+		case 256:
+			return pkix.AlgorithmIdentifier{Algorithm: oidECDSAWithSHA256}, sha256.New, nil
+		case 384:
+			return pkix.AlgorithmIdentifier{Algorithm: oidECDSAWithSHA384}, sha512.New384, nil
+		case 521:
+			return pkix.AlgorithmIdentifier{Algorithm: oidECDSAWithSHA512}, sha512.New, nil
+		}
+		return pkix.AlgorithmIdentifier{}, nil, ErrUnsuppontedCurve
+
+	case ed25519.PrivateKey:
+		return pkix.AlgorithmIdentifier{Algorithm: oidEd25519}, nil, nil
+
+	default:
+		return pkix.AlgorithmIdentifier{}, nil, ErrUnsupportedFormat
+
+	}
+
+}
+
+// sign signs message with the algorithm of signatureAlgorithm. Only ECDSA
+// signatures use randomness.
+func (k *Keydgen) sign(message []byte, newHash func() hash.Hash) ([]byte, error) {
+
+	var digest []byte
+	if newHash != nil {
+		h := newHash()
+		h.Write(message)
+		digest = h.Sum(nil)
+	}
+
+	switch key := k.privateKey.(type) {
+
+	case *rsa.PrivateKey:
+		// PKCS #1 v1.5 signatures are deterministic, without blinding
+		return rsa.SignPKCS1v15(nil, key, crypto.SHA256, digest)
+
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest)
+		if err != nil {
+			return nil, err
+		}
+		return asn1.Marshal(struct{ R, S *big.Int }{r, s})
+
+	case ed25519.PrivateKey:
+		return ed25519.Sign(key, message), nil
+
+	default:
+		return nil, ErrUnsupportedFormat
+
+	}
+
+}
+
+// extensions returns the certificate extensions in a fixed order
+func (t *CertificateTemplate) extensions(info subjectPublicKeyInfo) ([]pkix.Extension, error) {
+
+	var extensions []pkix.Extension
+	add := func(id asn1.ObjectIdentifier, critical bool, value interface{}) error {
+		b, err := asn1.Marshal(value)
+		if err == nil {
+			extensions = append(extensions, pkix.Extension{Id: id, Critical: critical, Value: b})
+		}
+		return err
+	}
+
+	id := sha1.Sum(info.PublicKey.Bytes)
+	if err := add(oidSubjectKeyID, false, id[:]); err != nil {
+		return nil, err
+	}
+
+	if t.KeyUsage != 0 {
+		var usage asn1.BitString
+		for bit := uint(0); bit < 9; bit++ {
+			if t.KeyUsage&(1<<bit) != 0 {
+				for len(usage.Bytes) <= int(bit/8) {
+					usage.Bytes = append(usage.Bytes, 0)
+				}
+				usage.Bytes[bit/8] |= 0x80 >> (bit % 8)
+				usage.BitLength = int(bit) + 1
+			}
+		}
+		if err := add(oidKeyUsage, true, usage); err != nil {
+			return nil, err
+		}
+	}
+
+	if t.IsCA {
+		if err := add(oidBasicConstraints, true, struct{ IsCA bool }{true}); err != nil {
+			return nil, err
+		}
+	} else if err := add(oidBasicConstraints, true, struct{}{}); err != nil {
+		return nil, err
+	}
+
+	if len(t.ExtKeyUsage) > 0 {
+		var usages []asn1.ObjectIdentifier
+		for _, usage := range t.ExtKeyUsage {
+			oid, ok := extKeyUsageOIDs[usage]
+			if !ok {
+				return nil, ErrUnsupportedExtKeyUsage
+			}
+			usages = append(usages, oid)
+		}
+		if err := add(oidExtKeyUsage, false, usages); err != nil {
+			return nil, err
+		}
+	}
+
+	// GeneralNames of RFC 5280, tagged by kind
+	var names []asn1.RawValue
+	for _, email := range t.EmailAddresses {
+		names = append(names, asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 1, Bytes: []byte(email)})
+	}
+	for _, dns := range t.DNSNames {
+		names = append(names, asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 2, Bytes: []byte(dns)})
+	}
+	for _, uri := range t.URIs {
+		names = append(names, asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 6, Bytes: []byte(uri)})
+	}
+	for _, ip := range t.IPAddresses {
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+		}
+		names = append(names, asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 7, Bytes: ip})
+	}
+	if len(names) > 0 {
+		// the names must be critical when they are the only identity
+		if err := add(oidSubjectAltName, len(t.Subject.ToRDNSequence()) == 0, names); err != nil {
+			return nil, err
+		}
+	}
+
+	return extensions, nil
+
+}
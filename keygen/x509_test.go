@@ -0,0 +1,176 @@
+package keygen
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/rand"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestMarshalCertificate(t *testing.T) {
+
+	template := &CertificateTemplate{
+		Subject:        pkix.Name{CommonName: "service.internal", Organization: []string{"Example"}},
+		DNSNames:       []string{"service.internal", "localhost"},
+		EmailAddresses: []string{"ops@example.com"},
+		IPAddresses:    []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("::1")},
+		URIs:           []string{"spiffe://example.com/service"},
+		NotBefore:      time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:       time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		KeyUsage:       x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	// ed25519 keys reproduce from the seed with every Go release, while
+	// rsa.GenerateKey randomly reads an extra byte since Go 1.11, and
+	// ecdsa signatures are random
+	cases := []struct {
+		k             *Keydgen
+		reproducible  bool
+		deterministic bool
+	}{
+		{&Keydgen{Type: RSA, Bits: 1024}, false, true},
+		{&Keydgen{Type: ECDSA, Curve: 256}, false, false},
+		{&Keydgen{Type: ECDSA, Curve: 384}, false, false},
+		{&Keydgen{Type: ECDSA, Curve: 521}, false, false},
+		{&Keydgen{Type: ED25519}, true, true},
+	}
+
+	for _, c := range cases {
+
+		key, err := c.k.GenerateKey(rand.New(rand.NewSource(1)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		first, err := c.k.MarshalCertificate(template)
+		if err != nil {
+			t.Fatalf("%s: %v", c.k.Type, err)
+		}
+
+		block, _ := pem.Decode(first)
+		if block == nil || block.Type != "CERTIFICATE" {
+			t.Fatalf("%s: expected a CERTIFICATE block", c.k.Type)
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatalf("%s: %v", c.k.Type, err)
+		}
+
+		if err = cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature); err != nil {
+			t.Fatalf("%s: %v", c.k.Type, err)
+		}
+
+		public := publicOf(key)
+		if public == nil {
+			public = key.(crypto.Signer).Public()
+		}
+		// the standard library parses Ed25519 keys into its own type
+		parsed := cert.PublicKey
+		if v := reflect.ValueOf(parsed); v.Kind() == reflect.Slice {
+			parsed = ed25519.PublicKey(v.Bytes())
+		}
+		if !reflect.DeepEqual(parsed, public) {
+			t.Fatalf("%s: public key does not match", c.k.Type)
+		}
+
+		if cert.Subject.CommonName != "service.internal" ||
+			!reflect.DeepEqual(cert.DNSNames, template.DNSNames) ||
+			!reflect.DeepEqual(cert.EmailAddresses, template.EmailAddresses) ||
+			len(cert.IPAddresses) != 2 || !cert.IPAddresses[1].Equal(net.ParseIP("::1")) ||
+			len(cert.URIs) != 1 || cert.URIs[0].String() != template.URIs[0] {
+			t.Fatalf("%s: names do not match", c.k.Type)
+		}
+
+		if cert.KeyUsage != template.KeyUsage || !reflect.DeepEqual(cert.ExtKeyUsage, template.ExtKeyUsage) || cert.IsCA {
+			t.Fatalf("%s: usages do not match", c.k.Type)
+		}
+
+		if !cert.NotBefore.Equal(template.NotBefore) || !cert.NotAfter.Equal(template.NotAfter) {
+			t.Fatalf("%s: validity does not match", c.k.Type)
+		}
+
+		// regenerate the key from the same seed
+		again := &Keydgen{Type: c.k.Type, Bits: c.k.Bits, Curve: c.k.Curve}
+		if _, err = again.GenerateKey(rand.New(rand.NewSource(1))); err != nil {
+			t.Fatal(err)
+		}
+
+		second, err := again.MarshalCertificate(template)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sameKey := reflect.DeepEqual(again.privateKey.(crypto.Signer).Public(), key.(crypto.Signer).Public())
+		if c.reproducible && !sameKey {
+			t.Fatalf("%s: key does not reproduce from the seed", c.k.Type)
+		}
+		if !sameKey {
+			t.Logf("%s: key does not reproduce from the seed with this Go release", c.k.Type)
+			continue
+		}
+
+		if c.deterministic && !bytes.Equal(first, second) {
+			t.Fatalf("%s: certificate does not reproduce from the seed", c.k.Type)
+		}
+
+	}
+
+}
+
+func TestMarshalCertificateSerial(t *testing.T) {
+
+	k := &Keydgen{Type: ED25519}
+	if _, err := k.GenerateKey(rand.New(rand.NewSource(1))); err != nil {
+		t.Fatal(err)
+	}
+
+	template := &CertificateTemplate{
+		Subject:   pkix.Name{CommonName: "ca"},
+		NotBefore: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:  time.Date(2034, 1, 1, 0, 0, 0, 0, time.UTC),
+		KeyUsage:  x509.KeyUsageCertSign,
+		IsCA:      true,
+	}
+
+	serials := map[string]bool{}
+	for _, days := range []int{0, 1} {
+
+		template.NotAfter = template.NotAfter.AddDate(0, 0, days)
+
+		b, err := k.MarshalCertificate(template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block, _ := pem.Decode(b)
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !cert.IsCA || cert.SerialNumber.Sign() <= 0 {
+			t.Fatal("expected a CA certificate with a positive serial")
+		}
+		serials[cert.SerialNumber.String()] = true
+
+	}
+
+	if len(serials) != 2 {
+		t.Fatal("expected certificates with different contents to have different serials")
+	}
+
+	template.NotAfter = template.NotBefore
+	if _, err := k.MarshalCertificate(template); err != ErrInvalidValidity {
+		t.Fatalf("expected %v, got %v", ErrInvalidValidity, err)
+	}
+
+}
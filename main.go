@@ -2,15 +2,26 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"crypto/rand"
 	"fmt"
 	"io/ioutil"
 	"net"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/cornfeedhobo/ssh-keydgen/keygen"
+	"github.com/cornfeedhobo/ssh-keydgen/mnemonic"
+	"github.com/cornfeedhobo/ssh-keydgen/recovery"
+	"github.com/cornfeedhobo/ssh-keydgen/secret"
+	"github.com/cornfeedhobo/ssh-keydgen/seedphrase"
 	"github.com/cornfeedhobo/ssh-keydgen/slowseeder"
+	"github.com/cornfeedhobo/ssh-keydgen/strength"
 	"github.com/mitchellh/go-homedir"
 	"golang.org/x/crypto/ed25519"
 	"golang.org/x/crypto/ssh/agent"
@@ -18,26 +29,55 @@ import (
 	"gopkg.in/urfave/cli.v1"
 )
 
+// kinds of errors, reported with --output json so that scripts can tell
+// failures apart without parsing messages
+const (
+	kindError       = "error"
+	kindUsage       = "usage"
+	kindSeedphrase  = "seedphrase"
+	kindFingerprint = "fingerprint"
+	kindSignature   = "signature"
+	kindExists      = "exists"
+	kindIO          = "io"
+	kindInterrupted = "interrupted"
+	kindBug         = "bug"
+)
+
+// exitError is an error with the exit status of the process and its kind
+type exitError struct {
+	*cli.ExitError
+	kind string
+}
+
 func newError(message string) error {
-	return cli.NewExitError(message, 1)
+	return newErrorKind(kindError, message)
+}
+
+func newErrorKind(kind, message string) error {
+	return &exitError{ExitError: cli.NewExitError(message, 1), kind: kind}
 }
 
 func newBug(message string) error {
-	return cli.NewExitError(message, 13)
+	return &exitError{ExitError: cli.NewExitError(message, 13), kind: kindBug}
 }
 
 func main() {
+	newApp().Run(os.Args)
+}
+
+func newApp() *cli.App {
+
 	app := cli.NewApp()
 
 	app.Name = "ssh-keygen"
-	app.Version = "0.4.0"
+	app.Version = "0.5.0"
 
 	app.Author = "cornfeedhobo"
 	app.Copyright = "(c) 2018 cornfeedhobo"
 
 	app.HelpName = "ssh-keygen"
 	app.Usage = "deterministic authentication key generation"
-	app.UsageText = "ssh-keygen [[-t <type>] [-b <bits>] [-c <curve>] [-f <filename>] [-a <rounds>] [--at <time>] [--am <memory>] [--as <seedphrase>] [--aa]]"
+	app.UsageText = "ssh-keygen [options]\n   ssh-keygen [options] command [command options] [arguments...]"
 
 	app.HideHelp = true
 	app.HideVersion = true
@@ -46,7 +86,7 @@ func main() {
 		cli.StringFlag{
 			Name:  "t",
 			Value: "rsa",
-			Usage: "Specifies the `type` of key to create. The possible values are \"dsa\", \"ecdsa\", \"rsa\", or \"ed25519\".",
+			Usage: "Specifies the `type` of key to create. The possible values are \"dsa\", \"ecdsa\", \"rsa\", \"ed25519\", \"age\" for an age X25519 identity, or \"wireguard\" for a WireGuard key pair.",
 		},
 		cli.IntFlag{
 			Name:  "b",
@@ -62,6 +102,39 @@ func main() {
 			Name:  "f",
 			Usage: "Specifies the `filename` of the key file.",
 		},
+		cli.BoolFlag{
+			Name:  "force",
+			Usage: "Overwrites an existing key file without asking.",
+		},
+		cli.BoolFlag{
+			Name:  "no-overwrite",
+			Usage: "Fails instead of asking when the key file already exists.",
+		},
+		cli.StringFlag{
+			Name:  "format",
+			Value: formatSSH,
+			Usage: "Specifies the `format` of the key files. The possible values are \"ssh\" for the formats read by OpenSSH, \"pkcs8\" for PKCS #8 and SubjectPublicKeyInfo PEM, \"ppk\" and \"ppk2\" for PuTTY private key files of version 3 and 2, \"jwk\" for JSON Web Keys, or \"openpgp\" for armored OpenPGP keys. pkcs8 and jwk do not support dsa, and openpgp only supports ed25519 and rsa.",
+		},
+		cli.StringFlag{
+			Name:  "passphrase-env",
+			Usage: "Encrypts ppk key files with the passphrase read from the environment variable `name`, which is then unset.",
+		},
+		cli.StringFlag{
+			Name:  "pgp-uid",
+			Usage: "Specifies the OpenPGP user `id`, such as \"Name <email>\". (default: the comment)",
+		},
+		cli.StringFlag{
+			Name:  "pgp-created",
+			Usage: "Specifies the OpenPGP key creation `date`, as YYYY-MM-DD or RFC 3339. Required with --format openpgp, as it is part of the fingerprint.",
+		},
+		cli.BoolFlag{
+			Name:  "pgp-subkey",
+			Usage: "Adds a Curve25519 encryption subkey to OpenPGP keys, derived from the same seedphrase under the label with \"/openpgp-encryption\" appended.",
+		},
+		cli.StringFlag{
+			Name:  "C",
+			Usage: "Provides a new `comment` for the public key.",
+		},
 		cli.IntFlag{
 			Name:  "a",
 			Value: 1000,
@@ -82,72 +155,515 @@ func main() {
 			Value: 1,
 			Usage: "Specifies the `threads` or parallelism for the Argon2 function.",
 		},
+		cli.StringFlag{
+			Name:  "al",
+			Usage: "Specifies the `label` used to salt the derivation, allowing many keys from one seedphrase.",
+		},
 		cli.StringFlag{
 			Name:  "as",
-			Usage: "Provides the deterministic `seedphrase`.",
+			Usage: "Provides the deterministic `seedphrase`. It is visible to other users and saved in shell history, prefer --seed-file, --seed-fd or --seed-env.",
+		},
+		cli.StringFlag{
+			Name:  "seed-file",
+			Usage: "Reads the seedphrase from `file`, such as one mounted from a secrets vault.",
+		},
+		cli.IntFlag{
+			Name:  "seed-fd",
+			Usage: "Reads the seedphrase from the open file descriptor `fd`.",
+		},
+		cli.StringFlag{
+			Name:  "seed-env",
+			Usage: "Reads the seedphrase from the environment variable `name`, which is then unset.",
+		},
+		cli.StringFlag{
+			Name:  "keyfile",
+			Usage: "Requires the contents of `file` in addition to the seedphrase. Any file works, but it must never change.",
+		},
+		cli.StringFlag{
+			Name:  "normalize",
+			Value: string(seedphrase.NFKD),
+			Usage: "Specifies the Unicode `form` used to canonicalize the seedphrase. The possible values are \"nfkd\", \"nfc\", or \"raw\" for keys made by releases before 0.5.0.",
+		},
+		cli.BoolFlag{
+			Name:  "mnemonic",
+			Usage: "Requires the seedphrase to be a valid BIP-39 mnemonic, catching typos before they produce a wrong key.",
+		},
+		cli.IntFlag{
+			Name:  "gen-mnemonic",
+			Usage: "Generates a new BIP-39 mnemonic seedphrase of `words` words (12, 18 or 24) and displays it once.",
+		},
+		cli.IntFlag{
+			Name:  "min-entropy",
+			Usage: "Refuses seedphrases with an estimated strength below `bits`.",
+		},
+		cli.BoolFlag{
+			Name:  "mlock",
+			Usage: "Locks memory to keep the seedphrase and key out of swap. Only supported on Linux, and Argon2 memory counts against the memlock limit.",
 		},
 		cli.BoolFlag{
 			Name:  "aa",
 			Usage: "Add the generated key to the running ssh-agent.",
 		},
+		cli.StringFlag{
+			Name:  "card",
+			Usage: "Writes a printable recovery card to `file`, as SVG if the name ends in .svg and plain text otherwise.",
+		},
+		cli.BoolFlag{
+			Name:  "qr",
+			Usage: "Prints the recovery parameters and fingerprint as a QR code.",
+		},
+		cli.StringFlag{
+			Name:  "qr-file",
+			Usage: "Writes the recovery QR code to `file`, as SVG if the name ends in .svg and PNG otherwise.",
+		},
+		cli.StringFlag{
+			Name:  "wg-config",
+			Usage: "Writes a WireGuard [Interface] configuration stub holding the private key to `file`. Only for wireguard keys.",
+		},
+		cli.StringFlag{
+			Name:  "import",
+			Usage: "Loads the parameters from a scanned recovery QR code `string`, verifying the fingerprint after generation.",
+		},
+		cli.StringFlag{
+			Name:  "output",
+			Value: "text",
+			Usage: "Specifies the output `format`. The possible values are \"text\", or \"json\" for a single JSON object on stdout with everything else on stderr.",
+		},
+		cli.StringFlag{
+			Name:  "config",
+			Usage: "Specifies the configuration `file` containing key profiles. (default: \"~/.config/ssh-keydgen/config.toml\")",
+		},
+		cli.StringFlag{
+			Name:  "profile",
+			Usage: "Loads the named `profile` from the configuration file. Flags override profile settings.",
+		},
+	}
+
+	app.Commands = []cli.Command{
+		{
+			Name:      "split",
+			Usage:     "Splits a seedphrase into shares, any threshold of which can reconstruct it",
+			ArgsUsage: " ",
+			Flags: []cli.Flag{
+				cli.IntFlag{
+					Name:  "n",
+					Value: 5,
+					Usage: "Specifies the number of `shares` to create.",
+				},
+				cli.IntFlag{
+					Name:  "k",
+					Value: 3,
+					Usage: "Specifies the `threshold` of shares needed to reconstruct the seedphrase.",
+				},
+			},
+			Action: withOutput("split", splitAction),
+		},
+		{
+			Name:      "combine",
+			Usage:     "Generates a key from a seedphrase reconstructed from shares, read from the arguments, stdin or prompts",
+			ArgsUsage: "[share...]",
+			Action:    withOutput("combine", combineAction),
+		},
+		{
+			Name:      "batch",
+			Usage:     "Generates every key listed in a JSON or YAML manifest from one seedphrase, each salted with its own label",
+			ArgsUsage: "manifest.json|manifest.yaml",
+			Flags: []cli.Flag{
+				cli.IntFlag{
+					Name:  "jobs",
+					Value: runtime.NumCPU(),
+					Usage: "Specifies the number of keys generated in parallel. Each uses the Argon2 memory.",
+				},
+				cli.StringFlag{
+					Name:  "report",
+					Usage: "Writes the summary of fingerprints to `file` as well as stdout.",
+				},
+				cli.StringFlag{
+					Name:  "jwks",
+					Usage: "Writes the public keys to `file` as a JSON Web Key Set, identified by their thumbprints. Not supported for dsa.",
+				},
+			},
+			Action: withOutput("batch", batchAction),
+		},
+		{
+			Name:      "x509",
+			Usage:     "Generates a key and a self-signed X.509 certificate for it, identical every time for ed25519 keys",
+			ArgsUsage: " ",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "subject",
+					Usage: "Specifies the subject `name`, either a common name or attributes such as \"CN=host,O=Example\". The supported attributes are CN, O, OU, L, ST and C.",
+				},
+				cli.StringSliceFlag{
+					Name:  "san",
+					Usage: "Adds a subject alternative `name`, detected as an IP address, URI, email address or otherwise DNS name. May be repeated.",
+				},
+				cli.StringFlag{
+					Name:  "not-before",
+					Usage: "Specifies the `date` the certificate becomes valid, as YYYY-MM-DD or RFC 3339. Required, so the certificate can be reproduced.",
+				},
+				cli.IntFlag{
+					Name:  "days",
+					Value: 365,
+					Usage: "Specifies the number of `days` the certificate is valid for.",
+				},
+				cli.StringFlag{
+					Name:  "key-usage",
+					Value: "digitalSignature",
+					Usage: "Specifies the comma separated key `usages`. The possible values are digitalSignature, contentCommitment, keyEncipherment, dataEncipherment, keyAgreement, certSign and crlSign.",
+				},
+				cli.StringFlag{
+					Name:  "ext-key-usage",
+					Usage: "Specifies the comma separated extended key `usages`. The possible values are serverAuth, clientAuth, codeSigning, emailProtection, timeStamping, ocspSigning and any.",
+				},
+				cli.BoolFlag{
+					Name:  "ca",
+					Usage: "Marks the certificate as a certificate authority. Add certSign to --key-usage to sign other certificates.",
+				},
+				cli.StringFlag{
+					Name:  "cert",
+					Usage: "Specifies the certificate `file`. (default: the key filename with .crt appended)",
+				},
+			},
+			Action: withOutput("x509", x509Action),
+		},
+		{
+			Name:      "sign",
+			Usage:     "Signs files, or else stdin, with the derived key in the SSH signature format of ssh-keygen -Y sign",
+			ArgsUsage: "[file...]",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "n",
+					Usage: "Specifies the `namespace` the signature is made for, such as \"git\" or \"file\". Required.",
+				},
+			},
+			Action: withOutput("sign", signAction),
+		},
+		{
+			Name:      "verify",
+			Usage:     "Verifies an SSH signature of a file, or else stdin, as made by sign or ssh-keygen -Y sign",
+			ArgsUsage: "[file]",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "n",
+					Usage: "Specifies the `namespace` the signature must have been made for. Required.",
+				},
+				cli.StringFlag{
+					Name:  "s",
+					Usage: "Specifies the signature `file`. (default: the file with .sig appended)",
+				},
+				cli.StringFlag{
+					Name:  "key",
+					Usage: "Requires the signature to be made by one of the public keys in `file`, in authorized_keys format.",
+				},
+			},
+			Action: withOutput("verify", verifyAction),
+		},
+		{
+			Name:      "allowed-signers",
+			Usage:     "Writes an allowed signers file for ssh-keygen -Y verify and git, with keys derived from labels or read from .pub files listed in a JSON manifest",
+			ArgsUsage: "manifest.json",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "o",
+					Usage: "Writes the allowed signers to `file` instead of stdout.",
+				},
+				cli.StringFlag{
+					Name:  "namespaces",
+					Value: "git",
+					Usage: "Specifies the comma separated `namespaces` the keys may sign for, or \"\" for any.",
+				},
+				cli.StringFlag{
+					Name:  "valid-after",
+					Usage: "Specifies the `date` from which the keys are trusted, as YYYY-MM-DD or RFC 3339.",
+				},
+				cli.StringFlag{
+					Name:  "valid-before",
+					Usage: "Specifies the `date` until which the keys are trusted, as YYYY-MM-DD or RFC 3339.",
+				},
+				cli.IntFlag{
+					Name:  "jobs",
+					Value: runtime.NumCPU(),
+					Usage: "Specifies the number of keys derived in parallel. Each uses the Argon2 memory.",
+				},
+			},
+			Action: withOutput("allowed-signers", allowedSignersAction),
+		},
+		{
+			Name:      "calibrate",
+			Usage:     "Benchmarks key derivation and recommends parameters that take the target duration on this machine",
+			ArgsUsage: " ",
+			Flags: []cli.Flag{
+				cli.DurationFlag{
+					Name:  "target",
+					Value: 5 * time.Second,
+					Usage: "Specifies the `duration` of a single derivation to aim for.",
+				},
+				cli.UintFlag{
+					Name:  "max-memory",
+					Value: 256 * 1024,
+					Usage: "Specifies the most `memory` in KiB the Argon2 function may use.",
+				},
+				cli.StringFlag{
+					Name:  "name",
+					Value: "calibrated",
+					Usage: "Specifies the `profile` name used in the printed configuration.",
+				},
+			},
+			Action: withOutput("calibrate", calibrateAction),
+		},
 	}
 
-	app.Action = appAction
+	app.Action = withOutput("generate", appAction)
+
+	return app
 
-	app.Run(os.Args)
+}
 
+func appAction(ctx *cli.Context) error {
+	return generate(ctx, getSeedphrase)
 }
 
-func appAction(ctx *cli.Context) (err error) {
+// generate derives a key from the seedphrase returned by source, then
+// writes it to a file or adds it to the running agent, followed by extras
+func generate(ctx *cli.Context, source func(*cli.Context) ([]byte, error), extras ...keyExtra) (err error) {
+
+	if err = lockMemory(ctx); err != nil {
+		return
+	}
+
+	var imported *recovery.Card
+	if imported, err = applyImport(ctx); err != nil {
+		return
+	}
+
+	if err = applyProfile(ctx); err != nil {
+		return
+	}
+
+	if err = checkImportedKeyfile(ctx, imported); err != nil {
+		return
+	}
 
 	ctx.Set("t", strings.ToLower(ctx.String("t")))
 
-	if ctx.Bool("aa") && os.Getenv("SSH_AUTH_SOCK") == "" {
-		return newError("SSH_AUTH_SOCK not set, unable to find running agent")
+	if _, err = seedphraseForm(ctx); err != nil {
+		return
 	}
 
-	fmt.Println("Generating public/private " + ctx.String("t") + " key pair")
+	var format *keyFormat
+	if format, err = newKeyFormat(ctx); err != nil {
+		return
+	}
+	defer format.wipe()
 
-	var seedphrase []byte
-	if seedphrase, err = getSeedphrase(ctx); err != nil {
+	if err = format.check(ctx.String("t")); err != nil {
 		return
 	}
 
+	if ctx.String("wg-config") != "" {
+		extras = append(extras, &wireguardConfig{filename: ctx.String("wg-config")})
+	}
+
+	for _, extra := range extras {
+		if err = extra.check(ctx.String("t")); err != nil {
+			return
+		}
+	}
+
+	if ctx.Bool("aa") && !keygen.SSHKeyType(ctx.String("t")) {
+		return newErrorKind(kindUsage, "Only SSH keys can be added to ssh-agent, not "+ctx.String("t")+" keys")
+	}
+
+	if ctx.Bool("aa") && os.Getenv("SSH_AUTH_SOCK") == "" {
+		return newError("SSH_AUTH_SOCK not set, unable to find running agent")
+	}
+
+	fmt.Fprintln(stdout, "Generating public/private "+ctx.String("t")+" key pair")
+
 	var filename string
 	if filename, err = getFilename(ctx); err != nil {
 		return
 	}
 
-	var keydgen = &keygen.Keydgen{
-		Type:  ctx.String("t"),
-		Bits:  uint16(ctx.Int("b")),
-		Curve: uint16(ctx.Int("c")),
+	var seedphrase []byte
+	if seedphrase, err = readSeedphrase(ctx, source); err != nil {
+		return
 	}
+	defer secret.Wipe(seedphrase)
 
-	rand, err := slowseeder.New(seedphrase, uint32(ctx.Int("a")), uint32(ctx.Uint("at")), uint32(ctx.Uint("am")), uint8(ctx.Uint("ap")))
+	keydgen, privateKey, err := deriveKey(ctx, seedphrase)
 	if err != nil {
-		return newError("Error with supplied parameters: " + err.Error())
+		return
 	}
+	defer keydgen.Wipe()
 
-	privateKey, err := keydgen.GenerateKey(rand)
-	if err != nil {
-		return newError("Error generating key: " + err.Error())
+	if err = format.deriveSubkey(ctx, seedphrase); err != nil {
+		return
 	}
 
+	if imported != nil && imported.Fingerprint != "" {
+		if err = verifyFingerprint(keydgen, imported.Fingerprint); err != nil {
+			return
+		}
+	}
+
+	var files []string
 	if ctx.Bool("aa") {
 		err = addKeyToAgent(privateKey)
-	} else {
-		err = writeKeyToFile(keydgen, filename)
+	} else if err = writeKeyToFile(keydgen, filename, format); err == nil {
+		files = append(files, filename, filename+".pub")
+	}
+
+	for _, extra := range extras {
+		if err != nil {
+			break
+		}
+		var written string
+		if written, err = extra.write(keydgen, filename); err == nil {
+			files = append(files, written)
+		}
+	}
+
+	if err == nil && ctx.String("card") != "" {
+		if err = writeRecoveryCard(ctx, keydgen); err == nil {
+			files = append(files, ctx.String("card"))
+		}
+	}
+
+	if err == nil && (ctx.Bool("qr") || ctx.String("qr-file") != "") {
+		if err = writeRecoveryQRCode(ctx, keydgen); err == nil && ctx.String("qr-file") != "" {
+			files = append(files, ctx.String("qr-file"))
+		}
+	}
+
+	if err == nil {
+		err = recordKey(ctx, keydgen, ctx.String("al"), files, ctx.Bool("aa"))
 	}
 
 	return
 
 }
 
+// readSeedphrase returns the seedphrase from source, mixed with the
+// keyfile if one was given
+func readSeedphrase(ctx *cli.Context, source func(*cli.Context) ([]byte, error)) ([]byte, error) {
+
+	seedphrase, err := source(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if ctx.String("keyfile") == "" {
+		return seedphrase, nil
+	}
+
+	mixed, err := mixKeyfile(seedphrase, ctx.String("keyfile"))
+	secret.Wipe(seedphrase)
+
+	return mixed, err
+
+}
+
+// deriveKey derives the key described by the global flags from seedphrase
+func deriveKey(ctx *cli.Context, seedphrase []byte) (*keygen.Keydgen, interface{}, error) {
+
+	var keydgen = &keygen.Keydgen{
+		Type:    ctx.String("t"),
+		Bits:    uint16(ctx.Int("b")),
+		Curve:   uint16(ctx.Int("c")),
+		Comment: ctx.String("C"),
+	}
+
+	label := derivationLabel(ctx.String("al"), keydgen.Type)
+	seeder, err := slowseeder.NewWithLabel(seedphrase, []byte(label), uint32(ctx.Int("a")), uint32(ctx.Uint("at")), uint32(ctx.Uint("am")), uint8(ctx.Uint("ap")))
+	if err != nil {
+		return nil, nil, newErrorKind(kindUsage, "Error with supplied parameters: "+err.Error())
+	}
+	defer seeder.Close()
+
+	privateKey, err := generateKey(keydgen, seeder)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return keydgen, privateKey, nil
+
+}
+
+// generateKey generates the key while showing progress, stopping before
+// the next derivation when interrupted
+func generateKey(keydgen *keygen.Keydgen, seeder *slowseeder.Reader) (interface{}, error) {
+
+	ctx, cancel := interruptContext()
+	defer cancel()
+
+	progress := startProgress()
+	seeder.OnProgress(progress.update)
+	privateKey, err := keydgen.GenerateKeyContext(ctx, seeder)
+	progress.stop()
+
+	if err == context.Canceled {
+		return nil, newErrorKind(kindInterrupted, "Key generation interrupted, no files were written")
+	}
+
+	if err != nil {
+		return nil, newError("Error generating key: " + err.Error())
+	}
+
+	return privateKey, nil
+
+}
+
+// interruptContext returns a context that is cancelled by Ctrl-C or SIGTERM
+func interruptContext() (context.Context, context.CancelFunc) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case <-interrupts:
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(interrupts)
+	}()
+
+	return ctx, cancel
+
+}
+
+// lockMemory locks the process memory when requested with --mlock
+func lockMemory(ctx *cli.Context) error {
+
+	if !ctx.Bool("mlock") {
+		return nil
+	}
+
+	if err := secret.LockMemory(); err != nil {
+		return newError("Unable to lock memory: " + err.Error())
+	}
+
+	return nil
+
+}
+
 func getFilename(ctx *cli.Context) (filename string, err error) {
 
+	if ctx.Bool("aa") {
+		return
+	}
+
+	if ctx.Bool("force") && ctx.Bool("no-overwrite") {
+		err = newErrorKind(kindUsage, "Only one of --force, --no-overwrite can be used")
+		return
+	}
+
 	filename = ctx.String("f")
-	if !ctx.Bool("aa") && filename == "" {
+	if filename == "" {
 		var home string
 		home, err = homedir.Dir()
 		if err != nil {
@@ -155,10 +671,14 @@ func getFilename(ctx *cli.Context) (filename string, err error) {
 			return
 		}
 
-		fmt.Printf("Enter file in which to save the key (%s/.ssh/id_%s): ", home, ctx.String("t"))
-		if _, err = fmt.Scanln(&filename); err != nil {
+		defaultFilename := filepath.Join(home, ".ssh", "id_"+ctx.String("t"))
+		filename, err = prompt("Enter file in which to save the key ("+defaultFilename+"): ", "use -f to name the key file")
+		if err != nil {
 			return
 		}
+		if filename == "" {
+			filename = defaultFilename
+		}
 	}
 
 	abspath, err := filepath.Abs(filename)
@@ -167,32 +687,38 @@ func getFilename(ctx *cli.Context) (filename string, err error) {
 		return
 	}
 
+	for _, name := range []string{abspath, abspath + ".pub"} {
+		if err = checkNotSymlink(name); err != nil {
+			return
+		}
+	}
+
 	_, privStatErr := os.Stat(abspath)
 	_, pubStatErr := os.Stat(abspath + ".pub")
 	if privStatErr == nil || pubStatErr == nil {
 
-		var strbool string
-		fmt.Println(filename + " already exists.")
-		fmt.Print("Overwrite (y/n)? ")
-		if _, err = fmt.Scanln(&strbool); err != nil {
+		if ctx.Bool("no-overwrite") {
+			err = newErrorKind(kindExists, filename+" already exists.")
 			return
 		}
 
-		if strings.TrimSpace(strings.ToLower(strbool)) == "y" {
+		if !ctx.Bool("force") {
+
+			fmt.Fprintln(stdout, filename+" already exists.")
 
-			err = os.Remove(abspath)
-			if err != nil && !os.IsNotExist(err) {
+			var answer string
+			answer, err = prompt("Overwrite (y/n)? ", "use --force to overwrite or --no-overwrite to keep it")
+			if err != nil {
 				return
 			}
 
-			err = os.Remove(abspath + ".pub")
-			if err != nil && !os.IsNotExist(err) {
+			if strings.ToLower(answer) != "y" {
+				err = newErrorKind(kindExists, "")
 				return
 			}
 
-		} else {
-			err = newError("")
 		}
+
 	}
 
 	return
@@ -201,11 +727,30 @@ func getFilename(ctx *cli.Context) (filename string, err error) {
 
 func getSeedphrase(ctx *cli.Context) (seed []byte, err error) {
 
-	stat, _ := os.Stdin.Stat()
+	if words := ctx.Int("gen-mnemonic"); words != 0 {
+		return generateMnemonic(words)
+	}
 
-	if (stat.Mode() & os.ModeCharDevice) == 0 {
+	if seed, err = readSeedSource(ctx); seed != nil || err != nil {
+		if err == nil {
+			seed, err = validateSeedphrase(ctx, seed, false)
+		}
+		return
+	}
+
+	if ctx.String("as") != "" {
+		fmt.Fprintln(os.Stderr, "warning: --as exposes the seedphrase to other users and shell history, prefer --seed-file, --seed-fd or --seed-env")
+	}
 
-		seed, err = ioutil.ReadAll(os.Stdin)
+	if ctx.String("as") == "" && !isTerminal() {
+
+		seed, err = ioutil.ReadAll(stdin)
+		if err == nil && len(seed) == 0 {
+			return nil, newErrorKind(kindSeedphrase, "Seedphrase from stdin is empty")
+		}
+		if err == nil {
+			seed, err = validateSeedphrase(ctx, seed, false)
+		}
 
 	} else {
 
@@ -218,9 +763,9 @@ func getSeedphrase(ctx *cli.Context) (seed []byte, err error) {
 
 			seed = []byte(ctx.String("as"))
 			for len(seed) == 0 {
-				fmt.Print("Enter seedphrase (can not be empty): ")
+				fmt.Fprint(stdout, "Enter seedphrase (can not be empty): ")
 				seed, err = terminal.ReadPassword(fd)
-				fmt.Print("\n")
+				fmt.Fprint(stdout, "\n")
 				if err != nil {
 					break
 				}
@@ -228,17 +773,29 @@ func getSeedphrase(ctx *cli.Context) (seed []byte, err error) {
 
 			verify := []byte(ctx.String("as"))
 			for len(verify) == 0 {
-				fmt.Print("Verify seedphrase (can not be empty): ")
+				fmt.Fprint(stdout, "Verify seedphrase (can not be empty): ")
 				verify, err = terminal.ReadPassword(fd)
-				fmt.Print("\n")
+				fmt.Fprint(stdout, "\n")
 				if err != nil {
 					break
 				}
 			}
 
 			equal = bytes.Equal(seed, verify)
+			secret.Wipe(verify)
 			if !equal {
-				fmt.Print("\nerror: seedphrases did not match\n\n")
+				secret.Wipe(seed)
+				fmt.Fprint(stdout, "\nerror: seedphrases did not match\n\n")
+				continue
+			}
+
+			var validationErr error
+			if seed, validationErr = validateSeedphrase(ctx, seed, ctx.String("as") == ""); validationErr != nil {
+				if ctx.String("as") != "" {
+					return nil, validationErr
+				}
+				fmt.Fprint(stdout, "\nerror: "+validationErr.Error()+"\n\n")
+				equal = false
 			}
 
 		}
@@ -249,6 +806,184 @@ func getSeedphrase(ctx *cli.Context) (seed []byte, err error) {
 
 }
 
+// readSeedSource reads the seedphrase from the file, file descriptor or
+// environment variable given by flags, returning nil if none were given
+func readSeedSource(ctx *cli.Context) (seed []byte, err error) {
+
+	var sources []string
+	for _, flag := range []string{"seed-file", "seed-fd", "seed-env"} {
+		if ctx.IsSet(flag) {
+			sources = append(sources, "--"+flag)
+		}
+	}
+
+	switch len(sources) {
+	case 0:
+		return nil, nil
+	case 1:
+	default:
+		return nil, newErrorKind(kindUsage, "Only one of "+strings.Join(sources, ", ")+" can be used")
+	}
+
+	switch {
+
+	case ctx.IsSet("seed-file"):
+		seed, err = ioutil.ReadFile(ctx.String("seed-file"))
+
+	case ctx.IsSet("seed-fd"):
+		f := os.NewFile(uintptr(ctx.Int("seed-fd")), "seed-fd")
+		if f == nil {
+			return nil, newErrorKind(kindUsage, "Invalid file descriptor for --seed-fd")
+		}
+		seed, err = ioutil.ReadAll(f)
+		f.Close()
+
+	case ctx.IsSet("seed-env"):
+		name := ctx.String("seed-env")
+		seed = []byte(os.Getenv(name))
+		// keep the seedphrase away from anything started later
+		os.Unsetenv(name)
+
+	}
+
+	if err != nil {
+		return nil, newErrorKind(kindIO, "Error reading seedphrase from "+sources[0]+": "+err.Error())
+	}
+
+	if len(seed) == 0 {
+		return nil, newErrorKind(kindSeedphrase, "Seedphrase from "+sources[0]+" is empty")
+	}
+
+	return seed, nil
+
+}
+
+func mixKeyfile(seed []byte, filename string) ([]byte, error) {
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, newErrorKind(kindIO, "Error reading keyfile: "+err.Error())
+	}
+	defer f.Close()
+
+	seed, err = slowseeder.MixKeyfile(seed, f)
+	if err != nil {
+		return nil, newErrorKind(kindIO, "Error reading keyfile: "+err.Error())
+	}
+
+	return seed, nil
+
+}
+
+func generateMnemonic(words int) ([]byte, error) {
+
+	phrase, err := mnemonic.Generate(rand.Reader, words)
+	if err != nil {
+		return nil, newError("Error generating mnemonic: " + err.Error())
+	}
+
+	fmt.Fprint(stdout, "\nWrite down this seedphrase and keep it safe. It will not be shown again.\n\n")
+	fmt.Fprint(stdout, "    "+phrase+"\n\n")
+
+	return []byte(phrase), nil
+
+}
+
+// validateSeedphrase canonicalizes seed and checks it against the mnemonic
+// and strength policies, reporting its strength when it was typed in
+func validateSeedphrase(ctx *cli.Context, seed []byte, interactive bool) ([]byte, error) {
+
+	form, err := seedphraseForm(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return checkSeedphrase(ctx, seedphrase.Canonicalize(seed, form), interactive)
+
+}
+
+// checkSeedphrase checks an already canonical seed against the mnemonic
+// and strength policies
+func checkSeedphrase(ctx *cli.Context, seed []byte, interactive bool) (_ []byte, err error) {
+
+	if ctx.Bool("mnemonic") {
+		if seed, err = checkMnemonic(seed); err != nil {
+			return nil, err
+		}
+	}
+
+	if ctx.Int("min-entropy") == 0 && !interactive {
+		return seed, nil
+	}
+
+	return seed, checkStrength(ctx, seed)
+
+}
+
+func seedphraseForm(ctx *cli.Context) (seedphrase.Form, error) {
+
+	form, err := seedphrase.ParseForm(ctx.String("normalize"))
+	if err != nil {
+		return "", newErrorKind(kindUsage, err.Error())
+	}
+
+	return form, nil
+
+}
+
+func checkStrength(ctx *cli.Context, seed []byte) error {
+
+	perGuess, err := estimateDerivation(ctx)
+	if err != nil {
+		return err
+	}
+
+	result := strength.Estimate(seed)
+	guessTime := strength.FormatSeconds(strength.GuessTime(result.Bits, perGuess))
+
+	fmt.Fprintf(stdout, "Estimated seedphrase strength is %.0f bits, %s to guess at about %s per guess\n", result.Bits, guessTime, perGuess.Round(time.Millisecond))
+
+	if min := ctx.Int("min-entropy"); float64(min) > result.Bits {
+		return newErrorKind(kindSeedphrase, fmt.Sprintf("Seedphrase is too weak, at least %d bits are required", min))
+	}
+
+	return nil
+
+}
+
+// estimateDerivation estimates the time of a single derivation with the
+// configured parameters, the minimum cost of testing one guessed
+// seedphrase, from short samples of PBKDF2 rounds and Argon2 memory
+func estimateDerivation(ctx *cli.Context) (time.Duration, error) {
+
+	rounds, err := measureDerivation(sampleRounds, 1, minMemoryPerThread, 1)
+	if err != nil {
+		return 0, err
+	}
+
+	memory, err := measureDerivation(1, 1, sampleMemory, 1)
+	if err != nil {
+		return 0, err
+	}
+
+	perRound := float64(rounds) / sampleRounds
+	perKiB := float64(memory) / sampleMemory
+
+	return time.Duration(perRound*float64(ctx.Int("a")) + perKiB*float64(ctx.Uint("at"))*float64(ctx.Uint("am"))), nil
+
+}
+
+func checkMnemonic(seed []byte) ([]byte, error) {
+
+	phrase := mnemonic.Normalize(string(seed))
+	if err := mnemonic.Validate(phrase); err != nil {
+		return nil, newErrorKind(kindSeedphrase, "Invalid mnemonic: "+err.Error())
+	}
+
+	return []byte(phrase), nil
+
+}
+
 func addKeyToAgent(privateKey interface{}) error {
 
 	conn, err := net.Dial("unix", os.Getenv("SSH_AUTH_SOCK"))
@@ -265,28 +1000,164 @@ func addKeyToAgent(privateKey interface{}) error {
 
 }
 
-func writeKeyToFile(k *keygen.Keydgen, filename string) error {
+func writeKeyToFile(k *keygen.Keydgen, filename string, format *keyFormat) error {
 
-	privBytes, err := k.MarshalPrivateKey()
+	privBytes, pubBytes, err := format.marshal(k)
 	if err != nil {
-		return newError(err.Error())
+		return err
 	}
+	defer secret.Wipe(privBytes)
 
-	pubBytes, err := k.MarshalPublicKey()
-	if err != nil {
-		return newError(err.Error())
+	if err = createSSHDir(filepath.Dir(filename)); err != nil {
+		return newErrorKind(kindIO, err.Error())
+	}
+
+	// both files are written out in full before either replaces the old key,
+	// and the private key is renamed last so a failure can be rolled back
+	files := []struct {
+		name string
+		data []byte
+		perm os.FileMode
+	}{
+		{filename + ".pub", pubBytes, 0644},
+		{filename, privBytes, 0600},
+	}
+
+	var temps []string
+	defer func() {
+		for _, temp := range temps {
+			os.Remove(temp)
+		}
+	}()
+
+	for _, f := range files {
+		if err = checkNotSymlink(f.name); err != nil {
+			return err
+		}
+		temp, err := writeTempFile(f.name, f.data, f.perm)
+		if err != nil {
+			return newErrorKind(kindIO, err.Error())
+		}
+		temps = append(temps, temp)
 	}
 
-	err = ioutil.WriteFile(filename, privBytes, 0600)
+	oldPub, oldPubErr := ioutil.ReadFile(files[0].name)
+
+	if err = os.Rename(temps[0], files[0].name); err != nil {
+		return newErrorKind(kindIO, err.Error())
+	}
+
+	if err = os.Rename(temps[1], files[1].name); err != nil {
+		// put back the public key of the private key left in place
+		if oldPubErr == nil {
+			replaceFile(files[0].name, oldPub, 0644)
+		} else {
+			os.Remove(files[0].name)
+		}
+		return newErrorKind(kindIO, err.Error())
+	}
+	temps = nil
+
+	syncDir(filepath.Dir(filename))
+
+	return nil
+
+}
+
+// createSSHDir creates ~/.ssh when it is missing and dir is ~/.ssh, so the
+// default key path works on a fresh account
+func createSSHDir(dir string) error {
+
+	home, err := homedir.Dir()
 	if err != nil {
-		return newError(err.Error())
+		return nil
 	}
 
-	err = ioutil.WriteFile(filename+".pub", pubBytes, 0600)
+	if filepath.Clean(dir) != filepath.Join(home, ".ssh") {
+		return nil
+	}
+
+	if _, err = os.Stat(dir); !os.IsNotExist(err) {
+		return nil
+	}
+
+	return os.Mkdir(dir, 0700)
+
+}
+
+// checkNotSymlink refuses key file paths that are symlinks, which could
+// redirect the key somewhere the user never intended
+func checkNotSymlink(filename string) error {
+
+	info, err := os.Lstat(filename)
+	if err == nil && info.Mode()&os.ModeSymlink != 0 {
+		return newErrorKind(kindExists, "Refusing to write key to symlink "+filename)
+	}
+
+	return nil
+
+}
+
+// replaceFile atomically writes data to filename, refusing symlinks
+func replaceFile(filename string, data []byte, perm os.FileMode) error {
+
+	if err := checkNotSymlink(filename); err != nil {
+		return err
+	}
+
+	temp, err := writeTempFile(filename, data, perm)
 	if err != nil {
-		return newError(err.Error())
+		return err
 	}
 
+	if err = os.Rename(temp, filename); err != nil {
+		os.Remove(temp)
+		return err
+	}
+
+	syncDir(filepath.Dir(filename))
+
 	return nil
 
 }
+
+// writeTempFile writes data to a synced temporary file beside filename
+// and returns its name, ready to be renamed into place
+func writeTempFile(filename string, data []byte, perm os.FileMode) (string, error) {
+
+	f, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp")
+	if err != nil {
+		return "", err
+	}
+
+	if err = f.Chmod(perm); err == nil {
+		if _, err = f.Write(data); err == nil {
+			err = f.Sync()
+		}
+	}
+
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+
+	return f.Name(), nil
+
+}
+
+// syncDir flushes the renames in dir to disk where the platform allows it
+func syncDir(dir string) {
+
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+
+	d.Sync()
+	d.Close()
+
+}
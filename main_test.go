@@ -1,17 +1,21 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/cornfeedhobo/ssh-keydgen/keygen"
 	"github.com/cornfeedhobo/ssh-keydgen/slowseeder"
+	"github.com/mitchellh/go-homedir"
 )
 
 func TestKeydgen(t *testing.T) {
@@ -95,7 +99,7 @@ func TestKeydgen(t *testing.T) {
 				t.Fatal(err)
 			}
 
-			err = writeKeyToFile(k, filename)
+			err = writeKeyToFile(k, filename, &keyFormat{name: formatSSH})
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -129,3 +133,277 @@ func TestKeydgen(t *testing.T) {
 		})
 	}
 }
+
+func TestWriteKeyToFile(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "ssh-keydgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	d, err := slowseeder.New([]byte("keygen"), 1, 1, 512, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k := &keygen.Keydgen{Type: keygen.ED25519}
+	if _, err = k.GenerateKey(d); err != nil {
+		t.Fatal(err)
+	}
+
+	filename := filepath.Join(dir, "id_ed25519")
+	if err = ioutil.WriteFile(filename, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = writeKeyToFile(k, filename, &keyFormat{name: formatSSH}); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, perm := range map[string]os.FileMode{filename: 0600, filename + ".pub": 0644} {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if runtime.GOOS != "windows" && info.Mode().Perm() != perm {
+			t.Errorf("%s has mode %v, expected %v", name, info.Mode().Perm(), perm)
+		}
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Errorf("expected only the key files to remain, found %d files", len(files))
+	}
+
+	link := filepath.Join(dir, "link")
+	if err = os.Symlink(filename, link); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+	if err = writeKeyToFile(k, link, &keyFormat{name: formatSSH}); err == nil {
+		t.Fatal("expected writing through a symlink to fail")
+	}
+
+}
+
+func TestReadSeedSource(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "ssh-keydgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	const phrase = "correct horse battery staple"
+
+	file, empty := filepath.Join(dir, "seed"), filepath.Join(dir, "empty")
+	if err = ioutil.WriteFile(file, []byte(phrase), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(empty, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteString(phrase)
+	w.Close()
+	fd := fmt.Sprint(r.Fd())
+
+	t.Setenv("SSH_KEYDGEN_TEST_SEED", phrase)
+	os.Unsetenv("SSH_KEYDGEN_TEST_EMPTY")
+
+	cases := []struct {
+		name string
+		args []string
+		seed string
+		kind string
+	}{
+		{"none", nil, "", ""},
+		{"file", []string{"-seed-file", file}, phrase, ""},
+		{"fd", []string{"-seed-fd", fd}, phrase, ""},
+		{"several", []string{"-seed-file", file, "-seed-env", "SSH_KEYDGEN_TEST_SEED"}, "", kindUsage},
+		{"env", []string{"-seed-env", "SSH_KEYDGEN_TEST_SEED"}, phrase, ""},
+		{"empty file", []string{"-seed-file", empty}, "", kindSeedphrase},
+		{"empty env", []string{"-seed-env", "SSH_KEYDGEN_TEST_EMPTY"}, "", kindSeedphrase},
+		{"missing file", []string{"-seed-file", filepath.Join(dir, "missing")}, "", kindIO},
+	}
+
+	for _, c := range cases {
+
+		seed, err := readSeedSource(testContext(c.args...))
+		if c.kind != "" {
+			if kinded, ok := err.(*exitError); !ok || kinded.kind != c.kind {
+				t.Errorf("%s: expected a %s error, got %v", c.name, c.kind, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+		} else if string(seed) != c.seed {
+			t.Errorf("%s: expected %q, got %q", c.name, c.seed, seed)
+		}
+
+	}
+
+	// readSeedSource closed the descriptor, so closing r only releases it
+	r.Close()
+
+	if _, ok := os.LookupEnv("SSH_KEYDGEN_TEST_SEED"); ok {
+		t.Error("expected --seed-env to unset the variable after reading it")
+	}
+
+}
+
+func TestSeedphraseArgumentWarning(t *testing.T) {
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	saved := os.Stderr
+	os.Stderr = w
+	seed, err := getSeedphrase(testContext("-as", "correct horse battery staple"))
+	os.Stderr = saved
+	w.Close()
+
+	warning, _ := ioutil.ReadAll(r)
+	r.Close()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(seed) != "correct horse battery staple" {
+		t.Fatalf("unexpected seedphrase %q", seed)
+	}
+	if !bytes.Contains(warning, []byte("warning: --as exposes the seedphrase")) {
+		t.Fatalf("expected a warning for --as, got %q", warning)
+	}
+
+}
+
+func TestGetFilename(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "ssh-keydgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	existing := filepath.Join(dir, "id_ed25519")
+	if err = ioutil.WriteFile(existing, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("HOME", dir)
+	homedir.DisableCache = true
+	defer func() { homedir.DisableCache = false }()
+
+	savedStdin, savedIsTerminal, savedStdout := stdin, isTerminal, stdout
+	defer func() { stdin, isTerminal, stdout = savedStdin, savedIsTerminal, savedStdout }()
+	stdout = ioutil.Discard
+
+	cases := []struct {
+		name     string
+		args     []string
+		terminal bool
+		input    string
+		filename string
+		kind     string
+	}{
+		{"force", []string{"-f", existing, "-force"}, false, "", existing, ""},
+		{"no overwrite", []string{"-f", existing, "-no-overwrite"}, true, "y\n", "", kindExists},
+		{"both", []string{"-f", existing, "-force", "-no-overwrite"}, true, "", "", kindUsage},
+		{"overwrite prompt", []string{"-f", existing}, true, "y\n", existing, ""},
+		{"overwrite declined", []string{"-f", existing}, true, "n\n", "", kindExists},
+		{"default", []string{"-t", "rsa"}, true, "\n", filepath.Join(dir, ".ssh", "id_rsa"), ""},
+		{"no terminal for the filename", []string{"-t", "rsa"}, false, "\n", "", kindUsage},
+		{"no terminal to confirm", []string{"-f", existing}, false, "y\n", "", kindUsage},
+	}
+
+	for _, c := range cases {
+
+		terminal := c.terminal
+		isTerminal = func() bool { return terminal }
+		stdin = bufio.NewReader(strings.NewReader(c.input))
+
+		filename, err := getFilename(testContext(c.args...))
+		if c.kind != "" {
+			if kinded, ok := err.(*exitError); !ok || kinded.kind != c.kind {
+				t.Errorf("%s: expected a %s error, got %v", c.name, c.kind, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+		} else if filename != c.filename {
+			t.Errorf("%s: expected %s, got %s", c.name, c.filename, filename)
+		}
+
+	}
+
+}
+
+func TestWriteKeyToFileRollback(t *testing.T) {
+
+	d, err := slowseeder.New([]byte("keygen"), 1, 1, 512, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k := &keygen.Keydgen{Type: keygen.ED25519}
+	if _, err = k.GenerateKey(d); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, oldPub := range []string{"old", ""} {
+
+		dir, err := ioutil.TempDir("", "ssh-keydgen")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		// a non-empty directory at the private key path fails its rename,
+		// which comes after the public key has already been replaced
+		filename := filepath.Join(dir, "id_ed25519")
+		if err = os.MkdirAll(filepath.Join(filename, "key"), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if oldPub != "" {
+			if err = ioutil.WriteFile(filename+".pub", []byte(oldPub), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err = writeKeyToFile(k, filename, &keyFormat{name: formatSSH}); err == nil {
+			t.Fatal("expected renaming the private key over a directory to fail")
+		}
+
+		b, err := ioutil.ReadFile(filename + ".pub")
+		if oldPub == "" && !os.IsNotExist(err) {
+			t.Errorf("expected the new public key to be removed, got %q", b)
+		}
+		if oldPub != "" && string(b) != oldPub {
+			t.Errorf("expected the old public key to be restored, got %q", b)
+		}
+
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range files {
+			if strings.Contains(f.Name(), ".tmp") {
+				t.Errorf("temporary file %s was left behind", f.Name())
+			}
+		}
+
+	}
+
+}
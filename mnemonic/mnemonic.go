@@ -0,0 +1,144 @@
+// Package mnemonic generates and validates BIP-39 style mnemonic seedphrases.
+//
+// A mnemonic encodes 128, 192 or 256 bits of entropy, plus a checksum
+// taken from its SHA256 hash, as 12, 18 or 24 words from the standard
+// English wordlist. The checksum catches most typos before they are
+// turned into a wrong key.
+package mnemonic
+
+import (
+	"crypto/sha256"
+	"errors"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalidLength is the error returned when a mnemonic does not have 12, 18 or 24 words
+	ErrInvalidLength = errors.New("mnemonic must have 12, 18 or 24 words")
+
+	// ErrInvalidChecksum is the error returned when the words of a mnemonic do not match its checksum
+	ErrInvalidChecksum = errors.New("mnemonic checksum is invalid, check for typos or swapped words")
+)
+
+// UnknownWordError is the error returned when a mnemonic contains a word missing from the wordlist
+type UnknownWordError struct {
+	Position int
+	Word     string
+}
+
+func (e *UnknownWordError) Error() string {
+	return "word " + strconv.Itoa(e.Position) + " (" + e.Word + ") is not in the wordlist"
+}
+
+var (
+	words   = strings.Fields(english)
+	indexes = make(map[string]int, len(words))
+)
+
+func init() {
+	for i, w := range words {
+		indexes[w] = i
+	}
+}
+
+// Wordlist returns a copy of the wordlist
+func Wordlist() []string {
+	return append([]string(nil), words...)
+}
+
+// Generate returns a new mnemonic of the given number of words using entropy read from rand
+func Generate(rand io.Reader, count int) (string, error) {
+
+	if !validCount(count) {
+		return "", ErrInvalidLength
+	}
+
+	entropy := make([]byte, count*4/3)
+	if _, err := io.ReadFull(rand, entropy); err != nil {
+		return "", err
+	}
+
+	return FromEntropy(entropy)
+
+}
+
+// FromEntropy returns the mnemonic encoding 16, 24 or 32 bytes of entropy
+func FromEntropy(entropy []byte) (string, error) {
+
+	count := len(entropy) * 3 / 4
+	if len(entropy)%4 != 0 || !validCount(count) {
+		return "", ErrInvalidLength
+	}
+
+	var (
+		checksumBits = uint(len(entropy) / 4)
+		n            = new(big.Int).SetBytes(entropy)
+		mask         = big.NewInt(2047)
+		phrase       = make([]string, count)
+	)
+
+	n.Lsh(n, checksumBits)
+	n.Or(n, big.NewInt(int64(checksum(entropy)>>(8-checksumBits))))
+
+	for i := count - 1; i >= 0; i-- {
+		phrase[i] = words[new(big.Int).And(n, mask).Int64()]
+		n.Rsh(n, 11)
+	}
+
+	return strings.Join(phrase, " "), nil
+
+}
+
+// Normalize lowercases the mnemonic and separates its words with single spaces
+func Normalize(phrase string) string {
+	return strings.Join(strings.Fields(strings.ToLower(phrase)), " ")
+}
+
+// Validate checks that every word of the mnemonic is in the wordlist and that its checksum is correct
+func Validate(phrase string) error {
+
+	fields := strings.Fields(Normalize(phrase))
+	if !validCount(len(fields)) {
+		return ErrInvalidLength
+	}
+
+	n := new(big.Int)
+	for i, w := range fields {
+		index, ok := indexes[w]
+		if !ok {
+			return &UnknownWordError{Position: i + 1, Word: w}
+		}
+		n.Lsh(n, 11)
+		n.Or(n, big.NewInt(int64(index)))
+	}
+
+	var (
+		checksumBits = uint(len(fields) / 3)
+		size         = len(fields) * 4 / 3
+		sum          = byte(new(big.Int).And(n, big.NewInt(1<<checksumBits-1)).Int64())
+		entropy      = make([]byte, size)
+	)
+
+	n.Rsh(n, checksumBits)
+	b := n.Bytes()
+	copy(entropy[size-len(b):], b)
+
+	if checksum(entropy)>>(8-checksumBits) != sum {
+		return ErrInvalidChecksum
+	}
+
+	return nil
+
+}
+
+func checksum(entropy []byte) byte {
+	sum := sha256.Sum256(entropy)
+	return sum[0]
+}
+
+func validCount(count int) bool {
+	return count == 12 || count == 18 || count == 24
+}
@@ -0,0 +1,77 @@
+package mnemonic
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestFromEntropy(t *testing.T) {
+
+	vectors := []struct {
+		entropy, phrase string
+	}{
+		{"00000000000000000000000000000000", strings.Repeat("abandon ", 11) + "about"},
+		{"7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f", "legal winner thank year wave sausage worth useful legal winner thank yellow"},
+		{"80808080808080808080808080808080", "letter advice cage absurd amount doctor acoustic avoid letter advice cage above"},
+		{"ffffffffffffffffffffffffffffffff", strings.Repeat("zoo ", 11) + "wrong"},
+		{"000000000000000000000000000000000000000000000000", strings.Repeat("abandon ", 17) + "agent"},
+		{"0000000000000000000000000000000000000000000000000000000000000000", strings.Repeat("abandon ", 23) + "art"},
+		{"ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff", strings.Repeat("zoo ", 23) + "vote"},
+	}
+
+	for _, v := range vectors {
+		entropy, _ := hex.DecodeString(v.entropy)
+		phrase, err := FromEntropy(entropy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if phrase != v.phrase {
+			t.Errorf("%s: expected %q, got %q", v.entropy, v.phrase, phrase)
+		}
+		if err = Validate(phrase); err != nil {
+			t.Errorf("%s: %s", v.entropy, err)
+		}
+	}
+
+}
+
+func TestGenerate(t *testing.T) {
+
+	for _, count := range []int{12, 18, 24} {
+		phrase, err := Generate(bytes.NewReader(bytes.Repeat([]byte{0x42}, 32)), count)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := len(strings.Fields(phrase)); n != count {
+			t.Fatalf("expected %d words, got %d", count, n)
+		}
+	}
+
+	if _, err := Generate(bytes.NewReader(make([]byte, 32)), 13); err != ErrInvalidLength {
+		t.Fatalf("expected ErrInvalidLength, got %v", err)
+	}
+
+}
+
+func TestValidate(t *testing.T) {
+
+	if err := Validate("  Legal WINNER thank year wave sausage worth useful legal winner thank\tyellow\n"); err != nil {
+		t.Fatalf("expected normalized phrase to validate, got %v", err)
+	}
+
+	if err := Validate("legal winner thank year wave sausage worth useful legal winner thank year"); err != ErrInvalidChecksum {
+		t.Fatalf("expected ErrInvalidChecksum, got %v", err)
+	}
+
+	err := Validate("legal winner thank year wave sausage worth usefull legal winner thank yellow")
+	if e, ok := err.(*UnknownWordError); !ok || e.Position != 8 || e.Word != "usefull" {
+		t.Fatalf("expected UnknownWordError for word 8, got %v", err)
+	}
+
+	if err := Validate("legal winner thank"); err != ErrInvalidLength {
+		t.Fatalf("expected ErrInvalidLength, got %v", err)
+	}
+
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/cornfeedhobo/ssh-keydgen/keygen"
+	"golang.org/x/crypto/ssh"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// stdout receives human readable output and prompts, which move to stderr
+// with --output json so that stdout carries nothing but the result
+var stdout io.Writer = os.Stdout
+
+// result is the machine readable summary of a run, printed with --output json
+type result struct {
+	Command     string             `json:"command"`
+	Keys        []*keyResult       `json:"keys,omitempty"`
+	Shares      *sharesResult      `json:"shares,omitempty"`
+	Calibration *derivationResult  `json:"calibration,omitempty"`
+	Signatures  []*signatureResult `json:"signatures,omitempty"`
+	// AllowedSigners is the allowed signers file, unless written to a file
+	AllowedSigners string       `json:"allowed_signers,omitempty"`
+	Error          *errorResult `json:"error,omitempty"`
+}
+
+// keyResult describes a generated key and where it went
+type keyResult struct {
+	Type         string            `json:"type"`
+	Bits         int               `json:"bits,omitempty"`
+	Curve        int               `json:"curve,omitempty"`
+	Comment      string            `json:"comment,omitempty"`
+	Label        string            `json:"label,omitempty"`
+	Derivation   *derivationResult `json:"derivation"`
+	PublicKey    string            `json:"public_key"`
+	Fingerprints map[string]string `json:"fingerprints,omitempty"`
+	Files        []string          `json:"files,omitempty"`
+	Agent        bool              `json:"agent"`
+}
+
+// derivationResult describes the parameters of the key derivation
+type derivationResult struct {
+	Scheme  string `json:"scheme,omitempty"`
+	Rounds  int    `json:"rounds"`
+	Time    uint   `json:"time"`
+	Memory  uint   `json:"memory"`
+	Threads uint   `json:"threads"`
+}
+
+// sharesResult describes the shares created by split
+type sharesResult struct {
+	SetID     string   `json:"set_id"`
+	Threshold int      `json:"threshold"`
+	Shares    []string `json:"shares"`
+}
+
+// signatureResult describes a signature made by sign or checked by verify,
+// File being empty for stdin
+type signatureResult struct {
+	File        string `json:"file,omitempty"`
+	Namespace   string `json:"namespace"`
+	Signature   string `json:"signature,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+// errorResult describes why a run failed, Code being the exit status and
+// Kind one of the kinds of errors
+type errorResult struct {
+	Code    int    `json:"code"`
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+// withOutput wraps the action of command, printing its result or error as
+// JSON on stdout when requested with --output json
+func withOutput(command string, action cli.ActionFunc) cli.ActionFunc {
+	return func(ctx *cli.Context) error {
+
+		root := ctx
+		for root.Parent() != nil {
+			root = root.Parent()
+		}
+
+		switch strings.ToLower(root.String("output")) {
+		case "text":
+			return action(ctx)
+		case "json":
+		default:
+			return newErrorKind(kindUsage, "Unsupported output format: "+root.String("output"))
+		}
+
+		stdout = os.Stderr
+		res := &result{Command: command}
+		ctx.App.Metadata["result"] = res
+
+		err := action(ctx)
+		if err != nil {
+			res.Error = &errorResult{Code: 1, Kind: kindError, Message: err.Error()}
+			if coder, ok := err.(cli.ExitCoder); ok {
+				res.Error.Code = coder.ExitCode()
+			}
+			if kinded, ok := err.(*exitError); ok {
+				res.Error.Kind = kinded.kind
+			}
+		}
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if encodeErr := encoder.Encode(res); encodeErr != nil && err == nil {
+			err = newBug(encodeErr.Error())
+		}
+
+		return err
+
+	}
+}
+
+// currentResult returns the result being collected, or nil without --output json
+func currentResult(ctx *cli.Context) *result {
+	res, _ := ctx.App.Metadata["result"].(*result)
+	return res
+}
+
+// newDerivationResult returns the derivation parameters set by the global flags
+func newDerivationResult(ctx *cli.Context) *derivationResult {
+	return &derivationResult{
+		Scheme:  derivationScheme(ctx),
+		Rounds:  ctx.Int("a"),
+		Time:    ctx.Uint("at"),
+		Memory:  ctx.Uint("am"),
+		Threads: ctx.Uint("ap"),
+	}
+}
+
+// recordKey adds a generated key to the result, if one is being collected
+func recordKey(ctx *cli.Context, k *keygen.Keydgen, label string, files []string, agent bool) error {
+
+	res := currentResult(ctx)
+	if res == nil {
+		return nil
+	}
+
+	publicKey, err := k.MarshalPublicKey()
+	if err != nil {
+		return newError(err.Error())
+	}
+
+	key := &keyResult{
+		Type:       k.Type,
+		Comment:    k.Comment,
+		Label:      label,
+		Derivation: newDerivationResult(ctx),
+		PublicKey:  strings.TrimSpace(string(publicKey)),
+		Files:      files,
+		Agent:      agent,
+	}
+
+	// age and WireGuard public keys have no fingerprint, being short enough to compare
+	if keygen.SSHKeyType(k.Type) {
+		pubKey, err := k.PublicKey()
+		if err != nil {
+			return newError(err.Error())
+		}
+		key.Fingerprints = map[string]string{
+			"sha256": ssh.FingerprintSHA256(pubKey),
+			"md5":    ssh.FingerprintLegacyMD5(pubKey),
+		}
+	}
+
+	switch k.Type {
+	case keygen.RSA, keygen.DSA:
+		key.Bits = int(k.Bits)
+	case keygen.ECDSA:
+		key.Curve = int(k.Curve)
+	}
+
+	res.Keys = append(res.Keys, key)
+
+	return nil
+
+}
+
+// recordSignature adds a signature to the result, if one is being collected
+func recordSignature(ctx *cli.Context, signature *signatureResult) {
+	if res := currentResult(ctx); res != nil {
+		res.Signatures = append(res.Signatures, signature)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/cornfeedhobo/ssh-keydgen/keygen"
+	"github.com/cornfeedhobo/ssh-keydgen/slowseeder"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// runWithOutput runs action with --output json, returning the decoded
+// result printed on stdout and the error
+func runWithOutput(t *testing.T, action cli.ActionFunc) (map[string]interface{}, error) {
+
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	set.String("output", "json", "")
+	set.String("normalize", "nfkd", "")
+	set.String("keyfile", "", "")
+	set.Int("a", 1000, "")
+	set.Uint("at", 3, "")
+	set.Uint("am", 16384, "")
+	set.Uint("ap", 1, "")
+
+	app := cli.NewApp()
+	app.Metadata = map[string]interface{}{}
+	ctx := cli.NewContext(app, set, nil)
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	saved, savedStdout := os.Stdout, stdout
+	os.Stdout = w
+	defer func() { os.Stdout, stdout = saved, savedStdout }()
+
+	actionErr := withOutput("test", action)(ctx)
+	w.Close()
+
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var res map[string]interface{}
+	if err = json.Unmarshal(b, &res); err != nil {
+		t.Fatalf("stdout is not a JSON result: %v\n%s", err, b)
+	}
+
+	return res, actionErr
+
+}
+
+func TestOutputSuccess(t *testing.T) {
+
+	d, err := slowseeder.New([]byte("keygen"), 1, 1, 512, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k := &keygen.Keydgen{Type: keygen.ED25519, Comment: "me@example.com"}
+	if _, err = k.GenerateKey(d); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := runWithOutput(t, func(ctx *cli.Context) error {
+		recordSignature(ctx, &signatureResult{File: "message.txt", Namespace: "file", Signature: "sig"})
+		return recordKey(ctx, k, "github.com", []string{"id_ed25519", "id_ed25519.pub"}, false)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res["command"] != "test" || res["error"] != nil {
+		t.Fatalf("unexpected result: %v", res)
+	}
+
+	keys, ok := res["keys"].([]interface{})
+	if !ok || len(keys) != 1 {
+		t.Fatalf("expected one key, got %v", res["keys"])
+	}
+	key := keys[0].(map[string]interface{})
+
+	for field, expected := range map[string]interface{}{
+		"type":    "ed25519",
+		"comment": "me@example.com",
+		"label":   "github.com",
+		"files":   []interface{}{"id_ed25519", "id_ed25519.pub"},
+		"agent":   false,
+		"derivation": map[string]interface{}{
+			"scheme":  "slowseeder/1+nfkd/1",
+			"rounds":  1000.0,
+			"time":    3.0,
+			"memory":  16384.0,
+			"threads": 1.0,
+		},
+	} {
+		if !reflect.DeepEqual(key[field], expected) {
+			t.Errorf("expected %s %v, got %v", field, expected, key[field])
+		}
+	}
+
+	if _, ok = key["public_key"].(string); !ok {
+		t.Errorf("expected a public key, got %v", key["public_key"])
+	}
+	if fingerprints, ok := key["fingerprints"].(map[string]interface{}); !ok || fingerprints["sha256"] == nil || fingerprints["md5"] == nil {
+		t.Errorf("expected sha256 and md5 fingerprints, got %v", key["fingerprints"])
+	}
+
+	expected := []interface{}{map[string]interface{}{"file": "message.txt", "namespace": "file", "signature": "sig"}}
+	if !reflect.DeepEqual(res["signatures"], expected) {
+		t.Errorf("expected signatures %v, got %v", expected, res["signatures"])
+	}
+
+}
+
+func TestOutputError(t *testing.T) {
+
+	cases := []struct {
+		err  error
+		code int
+		kind string
+	}{
+		{newError("failed"), 1, kindError},
+		{newErrorKind(kindExists, "failed"), 1, kindExists},
+		{newErrorKind(kindFingerprint, "failed"), 1, kindFingerprint},
+		{newBug("failed"), 13, kindBug},
+		{errors.New("failed"), 1, kindError},
+	}
+
+	for _, c := range cases {
+
+		res, err := runWithOutput(t, func(ctx *cli.Context) error {
+			return c.err
+		})
+		if err != c.err {
+			t.Fatalf("expected the action error, got %v", err)
+		}
+
+		expected := map[string]interface{}{
+			"command": "test",
+			"error": map[string]interface{}{
+				"code":    float64(c.code),
+				"kind":    c.kind,
+				"message": "failed",
+			},
+		}
+		if !reflect.DeepEqual(res, expected) {
+			t.Errorf("expected %v, got %v", expected, res)
+		}
+
+	}
+
+}
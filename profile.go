@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/cornfeedhobo/ssh-keydgen/config"
+	"github.com/mitchellh/go-homedir"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// applyProfile loads the profile selected with --profile and uses its
+// settings for every flag that was not explicitly provided
+func applyProfile(ctx *cli.Context) error {
+
+	name := ctx.String("profile")
+	if name == "" {
+		return nil
+	}
+
+	path := ctx.String("config")
+	if path == "" {
+		var err error
+		if path, err = config.DefaultPath(); err != nil {
+			return newBug(err.Error())
+		}
+	}
+
+	path, err := homedir.Expand(path)
+	if err != nil {
+		return newError(err.Error())
+	}
+
+	c, err := config.Load(path)
+	if os.IsNotExist(err) {
+		return newErrorKind(kindUsage, "Configuration file not found: "+path)
+	} else if err != nil {
+		return newErrorKind(kindIO, "Error reading configuration file: "+err.Error())
+	}
+
+	p, err := c.Profile(name)
+	if err != nil {
+		return newErrorKind(kindUsage, "Error loading profile "+strconv.Quote(name)+": "+err.Error())
+	}
+
+	for _, path := range []*string{&p.File, &p.Keyfile} {
+		if *path, err = homedir.Expand(*path); err != nil {
+			return newError(err.Error())
+		}
+	}
+
+	settings := []struct {
+		flag, value string
+		set         bool
+	}{
+		{"t", p.Type, p.Type != ""},
+		{"b", strconv.Itoa(p.Bits), p.Bits != 0},
+		{"c", strconv.Itoa(p.Curve), p.Curve != 0},
+		{"a", strconv.Itoa(p.Rounds), p.Rounds != 0},
+		{"at", strconv.FormatUint(uint64(p.Time), 10), p.Time != 0},
+		{"am", strconv.FormatUint(uint64(p.Memory), 10), p.Memory != 0},
+		{"ap", strconv.FormatUint(uint64(p.Threads), 10), p.Threads != 0},
+		{"al", p.Label, p.Label != ""},
+		{"C", p.Comment, p.Comment != ""},
+		{"f", p.File, p.File != ""},
+		{"keyfile", p.Keyfile, p.Keyfile != ""},
+		{"normalize", p.Normalize, p.Normalize != ""},
+		{"mnemonic", "true", p.Mnemonic},
+	}
+
+	for _, s := range settings {
+		if !s.set || ctx.IsSet(s.flag) {
+			continue
+		}
+		if err := ctx.Set(s.flag, s.value); err != nil {
+			return newErrorKind(kindUsage, "Invalid value for "+s.flag+" in profile "+strconv.Quote(name)+": "+err.Error())
+		}
+	}
+
+	return nil
+
+}
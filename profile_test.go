@@ -0,0 +1,55 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestApplyProfile(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "ssh-keydgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "config.toml")
+	if err = ioutil.WriteFile(filename, []byte(`[github]
+type = "ed25519"
+rounds = 2000
+time = 6
+label = "github.com"
+comment = "profile@example.com"
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := testContext("-config", filename, "-profile", "github", "-a", "5000", "-C", "flag@example.com")
+	if err = applyProfile(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for flag, expected := range map[string]string{
+		// unset flags are filled from the profile
+		"t":  "ed25519",
+		"at": "6",
+		"al": "github.com",
+		// explicit flags win over the profile
+		"a": "5000",
+		"C": "flag@example.com",
+		// flags the profile leaves out keep their defaults
+		"b":  "2048",
+		"am": "16384",
+	} {
+		if value := ctx.String(flag); value != expected {
+			t.Errorf("expected -%s %s, got %s", flag, expected, value)
+		}
+	}
+
+	if err = applyProfile(testContext("-config", filename, "-profile", "gitlab")); err == nil {
+		t.Fatal("expected an error for a missing profile")
+	}
+
+}
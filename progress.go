@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cornfeedhobo/ssh-keydgen/slowseeder"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// progressLine renders a spinner and the derivation progress on stderr
+// while a key is generated
+type progressLine struct {
+	mu      sync.Mutex
+	status  slowseeder.Progress
+	started time.Time
+	width   int
+
+	done    chan struct{}
+	stopped sync.WaitGroup
+}
+
+// startProgress starts rendering progress, or returns nil when stderr is
+// not a terminal that could display it
+func startProgress() *progressLine {
+
+	if !terminal.IsTerminal(int(os.Stderr.Fd())) {
+		return nil
+	}
+
+	p := &progressLine{started: time.Now(), done: make(chan struct{})}
+
+	p.stopped.Add(1)
+	go func() {
+		defer p.stopped.Done()
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		for frame := 0; ; frame++ {
+			select {
+			case <-p.done:
+				p.clear()
+				return
+			case <-ticker.C:
+				p.render(`|/-\`[frame%4])
+			}
+		}
+	}()
+
+	return p
+
+}
+
+// update records the latest progress reported by the seeder
+func (p *progressLine) update(status slowseeder.Progress) {
+
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	p.status = status
+	p.mu.Unlock()
+
+}
+
+// stop clears the progress line and waits for rendering to finish
+func (p *progressLine) stop() {
+
+	if p == nil {
+		return
+	}
+
+	close(p.done)
+	p.stopped.Wait()
+
+}
+
+func (p *progressLine) render(spinner byte) {
+
+	p.mu.Lock()
+	status := p.status
+	p.mu.Unlock()
+
+	line := fmt.Sprintf("%c Generating key: %d derivations, %d bytes, %s elapsed", spinner, status.Invocations, status.Bytes, time.Since(p.started).Round(time.Second))
+	padding := ""
+	if len(line) < p.width {
+		padding = strings.Repeat(" ", p.width-len(line))
+	}
+	p.width = len(line)
+
+	fmt.Fprint(os.Stderr, "\r"+line+padding)
+
+}
+
+func (p *progressLine) clear() {
+	if p.width > 0 {
+		fmt.Fprint(os.Stderr, "\r"+strings.Repeat(" ", p.width)+"\r")
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// stdin is shared by every prompt so buffered input is never lost between them
+var stdin = bufio.NewReader(os.Stdin)
+
+// isTerminal reports whether stdin is an interactive terminal.
+// It is a variable so tests can answer prompts without one.
+var isTerminal = func() bool {
+	return terminal.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// prompt prints message and returns the line typed in reply, failing
+// instead of waiting when there is nobody at a terminal to answer
+func prompt(message, hint string) (string, error) {
+
+	if !isTerminal() {
+		return "", newErrorKind(kindUsage, "Unable to prompt, stdin is not a terminal: "+hint)
+	}
+
+	fmt.Fprint(stdout, message)
+	line, err := stdin.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", newErrorKind(kindIO, "Error reading answer: "+err.Error())
+	}
+
+	return strings.TrimSpace(line), nil
+
+}
@@ -0,0 +1,111 @@
+package qrcode
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestEncodeVersion(t *testing.T) {
+
+	cases := []struct {
+		length  int
+		level   Level
+		version int
+	}{
+		{14, Medium, 1},
+		{15, Medium, 2},
+		{17, Low, 1},
+		{2331, Medium, 40},
+		{2953, Low, 40},
+	}
+
+	for _, c := range cases {
+		code, err := Encode([]byte(strings.Repeat("a", c.length)), c.level)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code.Version != c.version || code.Size != c.version*4+17 {
+			t.Errorf("length %d: expected version %d, got %d", c.length, c.version, code.Version)
+		}
+	}
+
+	if _, err := Encode(make([]byte, 2954), Low); err != ErrDataTooLong {
+		t.Fatalf("expected ErrDataTooLong, got %v", err)
+	}
+
+}
+
+func TestEncodeKnownAnswer(t *testing.T) {
+
+	// 1-M "HELLO WORLD" in byte mode with mask 3, as produced by rsc.io/qr
+	expected := []string{
+		"#######.#...#.#######",
+		"#.....#.#...#.#.....#",
+		"#.###.#.......#.###.#",
+		"#.###.#.#.#.#.#.###.#",
+		"#.###.#..###..#.###.#",
+		"#.....#...###.#.....#",
+		"#######.#.#.#.#######",
+		"........#####........",
+		"#.##.###.#.##.#..#.##",
+		".##....#.#######.##..",
+		".....#####.#.#.#...##",
+		"#.#.##.##..#...#.#.#.",
+		"#...#.##.##.##....#.#",
+		"........#.##..##..#.#",
+		"#######.#.#######....",
+		"#.....#.###..#.#.####",
+		"#.###.#..#..#.#..#...",
+		"#.###.#.###...#..###.",
+		"#.###.#.##..#..#..#..",
+		"#.....#..###.####...#",
+		"#######.##.#.#.#.....",
+	}
+
+	code, err := Encode([]byte("HELLO WORLD"), Medium)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code.Size != len(expected) {
+		t.Fatalf("expected size %d, got %d", len(expected), code.Size)
+	}
+
+	for y, row := range expected {
+		for x, m := range row {
+			if code.Black(x, y) != (m == '#') {
+				t.Fatalf("unexpected module at column %d, row %d", x, y)
+			}
+		}
+	}
+
+}
+
+func TestRender(t *testing.T) {
+
+	code, err := Encode([]byte("ssh-keydgen"), Medium)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the middle of the top left finder pattern crosses a dark ring, a light ring and a dark center
+	for i, dark := range []bool{true, false, true, true, true, false, true, false} {
+		if code.Black(i, 3) != dark || code.Black(3, i) != dark {
+			t.Fatalf("unexpected finder pattern at %d", i)
+		}
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := code.WritePNG(buf, 2); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := (code.Size + QuietZone*2) * 2; img.Bounds().Dx() != size {
+		t.Fatalf("expected %d pixel image, got %d", size, img.Bounds().Dx())
+	}
+
+}
@@ -0,0 +1,92 @@
+package qrcode
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+// QuietZone is the number of light modules surrounding every rendered symbol
+const QuietZone = 4
+
+// WriteANSI writes the code as ANSI colored blocks suitable for a terminal
+func (c *Code) WriteANSI(w io.Writer) error {
+
+	const (
+		light = "\x1b[47m  "
+		dark  = "\x1b[40m  "
+		reset = "\x1b[0m\n"
+	)
+
+	buf := bufio.NewWriter(w)
+
+	for y := -QuietZone; y < c.Size+QuietZone; y++ {
+		for x := -QuietZone; x < c.Size+QuietZone; x++ {
+			if c.Black(x, y) {
+				buf.WriteString(dark)
+			} else {
+				buf.WriteString(light)
+			}
+		}
+		buf.WriteString(reset)
+	}
+
+	return buf.Flush()
+
+}
+
+// Image returns the code as a grayscale image with each module scale pixels wide
+func (c *Code) Image(scale int) image.Image {
+
+	var (
+		size = (c.Size + QuietZone*2) * scale
+		img  = image.NewGray(image.Rect(0, 0, size, size))
+	)
+
+	for py := 0; py < size; py++ {
+		for px := 0; px < size; px++ {
+			v := color.White
+			if c.Black(px/scale-QuietZone, py/scale-QuietZone) {
+				v = color.Black
+			}
+			img.Set(px, py, v)
+		}
+	}
+
+	return img
+
+}
+
+// WritePNG writes the code as a PNG image with each module scale pixels wide
+func (c *Code) WritePNG(w io.Writer, scale int) error {
+	return png.Encode(w, c.Image(scale))
+}
+
+// WriteSVG writes the code as an SVG image with each module scale units wide
+func (c *Code) WriteSVG(w io.Writer, scale int) error {
+
+	var (
+		size = (c.Size + QuietZone*2) * scale
+		buf  = bufio.NewWriter(w)
+	)
+
+	fmt.Fprintf(buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+"\n", size, size, c.Size+QuietZone*2, c.Size+QuietZone*2)
+	fmt.Fprintf(buf, `<rect width="100%%" height="100%%" fill="white"/>`+"\n")
+	buf.WriteString(`<path fill="black" d="`)
+
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.Black(x, y) {
+				fmt.Fprintf(buf, "M%d,%dh1v1h-1z", x+QuietZone, y+QuietZone)
+			}
+		}
+	}
+
+	buf.WriteString("\"/>\n</svg>\n")
+
+	return buf.Flush()
+
+}
@@ -0,0 +1,108 @@
+package recovery
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Prefix begins every encoded card
+const Prefix = "ssh-keydgen:recovery?"
+
+// ErrInvalidEncoding is the error returned when decoding a string that is not an encoded card
+var ErrInvalidEncoding = errors.New("not an ssh-keydgen recovery string")
+
+// Encode returns the card as a compact string suitable for a QR code.
+// Parameters are keyed by their command line flag names.
+func (c *Card) Encode() string {
+
+	v := url.Values{}
+	v.Set("v", c.Version)
+	v.Set("s", c.Scheme)
+	v.Set("t", c.Type)
+
+	switch c.Type {
+	case "ecdsa":
+		v.Set("c", strconv.Itoa(int(c.Curve)))
+	case "rsa", "dsa":
+		v.Set("b", strconv.Itoa(int(c.Bits)))
+	}
+
+	v.Set("a", strconv.FormatUint(uint64(c.Rounds), 10))
+	v.Set("at", strconv.FormatUint(uint64(c.Time), 10))
+	v.Set("am", strconv.FormatUint(uint64(c.Memory), 10))
+	v.Set("ap", strconv.FormatUint(uint64(c.Threads), 10))
+
+	if c.Mnemonic {
+		v.Set("mnemonic", "1")
+	}
+	if c.Label != "" {
+		v.Set("al", c.Label)
+	}
+	if c.Comment != "" {
+		v.Set("C", c.Comment)
+	}
+	if c.Fingerprint != "" {
+		v.Set("fp", c.Fingerprint)
+	}
+
+	return Prefix + v.Encode()
+
+}
+
+// Decode parses a string created by Card.Encode
+func Decode(s string) (*Card, error) {
+
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, Prefix) {
+		return nil, ErrInvalidEncoding
+	}
+
+	v, err := url.ParseQuery(strings.TrimPrefix(s, Prefix))
+	if err != nil {
+		return nil, ErrInvalidEncoding
+	}
+
+	c := &Card{
+		Version:     v.Get("v"),
+		Scheme:      v.Get("s"),
+		Type:        v.Get("t"),
+		Label:       v.Get("al"),
+		Comment:     v.Get("C"),
+		Fingerprint: v.Get("fp"),
+		Mnemonic:    v.Get("mnemonic") == "1",
+	}
+
+	if c.Scheme == "" || c.Type == "" {
+		return nil, errors.New("recovery string is missing the derivation scheme or key type")
+	}
+
+	numbers := []struct {
+		key  string
+		bits int
+		set  func(uint64)
+	}{
+		{"b", 16, func(n uint64) { c.Bits = uint16(n) }},
+		{"c", 16, func(n uint64) { c.Curve = uint16(n) }},
+		{"a", 32, func(n uint64) { c.Rounds = uint32(n) }},
+		{"at", 32, func(n uint64) { c.Time = uint32(n) }},
+		{"am", 32, func(n uint64) { c.Memory = uint32(n) }},
+		{"ap", 8, func(n uint64) { c.Threads = uint8(n) }},
+	}
+
+	for _, number := range numbers {
+		value := v.Get(number.key)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.ParseUint(value, 10, number.bits)
+		if err != nil {
+			return nil, errors.New("recovery string has an invalid value for " + number.key)
+		}
+		number.set(n)
+	}
+
+	return c, nil
+
+}
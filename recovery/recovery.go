@@ -0,0 +1,146 @@
+// Package recovery renders recovery cards, printable summaries of every
+// parameter needed to regenerate a key from its seedphrase.
+package recovery
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Card represents everything, except the seedphrase, needed to regenerate a key
+type Card struct {
+	Version     string
+	Scheme      string
+	Type        string
+	Bits        uint16
+	Curve       uint16
+	Rounds      uint32
+	Time        uint32
+	Memory      uint32
+	Threads     uint8
+	Label       string
+	Comment     string
+	Fingerprint string
+	Mnemonic    bool
+}
+
+func seedphraseFormat(mnemonic bool) string {
+	if mnemonic {
+		return "BIP-39 mnemonic"
+	}
+	return "free-form"
+}
+
+type field struct {
+	name, value string
+}
+
+func (c *Card) fields() []field {
+
+	fields := []field{
+		{"Tool version", c.Version},
+		{"Derivation scheme", c.Scheme},
+		{"Key type", c.Type},
+	}
+
+	switch c.Type {
+	case "ecdsa":
+		fields = append(fields, field{"Curve", strconv.Itoa(int(c.Curve))})
+	case "rsa", "dsa":
+		fields = append(fields, field{"Bits", strconv.Itoa(int(c.Bits))})
+	}
+
+	fields = append(fields,
+		field{"PBKDF2 rounds", strconv.FormatUint(uint64(c.Rounds), 10)},
+		field{"Argon2 time", strconv.FormatUint(uint64(c.Time), 10)},
+		field{"Argon2 memory", strconv.FormatUint(uint64(c.Memory), 10) + " KiB"},
+		field{"Argon2 threads", strconv.FormatUint(uint64(c.Threads), 10)},
+		field{"Seedphrase", seedphraseFormat(c.Mnemonic)},
+		field{"Label", c.Label},
+		field{"Comment", c.Comment},
+		field{"Fingerprint", c.Fingerprint},
+	)
+
+	return fields
+
+}
+
+func (c *Card) lines() []string {
+
+	var (
+		fields = c.fields()
+		width  int
+		lines  = []string{"SSH-KEYDGEN RECOVERY CARD", ""}
+	)
+
+	for _, f := range fields {
+		if len(f.name) > width {
+			width = len(f.name)
+		}
+	}
+
+	for _, f := range fields {
+		lines = append(lines, fmt.Sprintf("%-*s  %s", width+1, f.name+":", f.value))
+	}
+
+	return append(lines,
+		"",
+		"Regenerate with the seedphrase and exactly these parameters.",
+		"This card does not contain the seedphrase; store them separately.",
+	)
+
+}
+
+// WriteText writes the card as plain text
+func (c *Card) WriteText(w io.Writer) error {
+	_, err := io.WriteString(w, strings.Join(c.lines(), "\n")+"\n")
+	return err
+}
+
+// WriteSVG writes the card as a self-contained, printable SVG image
+func (c *Card) WriteSVG(w io.Writer) error {
+
+	const (
+		fontSize   = 14
+		lineHeight = 20
+		charWidth  = 8.4
+		margin     = 24
+	)
+
+	var (
+		lines = c.lines()
+		buf   = bytes.NewBuffer(nil)
+		width int
+	)
+
+	for _, line := range lines {
+		if len(line) > width {
+			width = len(line)
+		}
+	}
+
+	svgWidth := int(float64(width)*charWidth) + margin*2
+	svgHeight := len(lines)*lineHeight + margin*2
+
+	fmt.Fprintf(buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n", svgWidth, svgHeight, svgWidth, svgHeight)
+	fmt.Fprintf(buf, `<rect x="1" y="1" width="%d" height="%d" fill="white" stroke="black" stroke-width="2"/>`+"\n", svgWidth-2, svgHeight-2)
+	fmt.Fprintf(buf, `<text font-family="monospace" font-size="%d" xml:space="preserve">`+"\n", fontSize)
+
+	for i, line := range lines {
+		fmt.Fprintf(buf, `<tspan x="%d" y="%d">`, margin, margin+(i+1)*lineHeight-lineHeight/4)
+		if err := xml.EscapeText(buf, []byte(line)); err != nil {
+			return err
+		}
+		buf.WriteString("</tspan>\n")
+	}
+
+	buf.WriteString("</text>\n</svg>\n")
+
+	_, err := buf.WriteTo(w)
+	return err
+
+}
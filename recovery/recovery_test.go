@@ -0,0 +1,111 @@
+package recovery
+
+import (
+	"bytes"
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+
+	card := &Card{Type: "ecdsa", Curve: 384, Bits: 2048, Label: "prod"}
+	buf := bytes.NewBuffer(nil)
+	if err := card.WriteText(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	text := buf.String()
+	if !strings.Contains(text, "Curve:") || strings.Contains(text, "Bits:") || !strings.Contains(text, "prod") {
+		t.Fatalf("unexpected card:\n%s", text)
+	}
+
+}
+
+func TestWriteSVG(t *testing.T) {
+
+	card := &Card{
+		Version: "0.4.0",
+		Scheme:  "slowseeder/1",
+		Type:    "ed25519",
+		Rounds:  1000,
+		Time:    3,
+		Memory:  16384,
+		Threads: 1,
+		Label:   "github.com",
+		Comment: "me@example.com & <friends>",
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := card.WriteSVG(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var svg struct {
+		Text struct {
+			Lines []string `xml:"tspan"`
+		} `xml:"text"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &svg); err != nil {
+		t.Fatal(err)
+	}
+
+	// every line of the text card survives escaping
+	text := bytes.NewBuffer(nil)
+	if err := card.WriteText(text); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(svg.Text.Lines, "\n")+"\n" != text.String() {
+		t.Fatalf("SVG lines differ from the text card:\n%s", strings.Join(svg.Text.Lines, "\n"))
+	}
+
+}
+
+func TestEncodeDecode(t *testing.T) {
+
+	cards := []*Card{
+		{
+			Version:     "0.4.0",
+			Scheme:      "slowseeder/1",
+			Type:        "ed25519",
+			Rounds:      1000,
+			Time:        3,
+			Memory:      16384,
+			Threads:     1,
+			Label:       "github.com",
+			Comment:     "me@example.com & friends",
+			Fingerprint: "SHA256:70yNDwlNmakVH1kMIsNSRXYG0/wwNBj94abLF34X4u0",
+			Mnemonic:    true,
+		},
+		{
+			Version: "0.4.0",
+			Scheme:  "slowseeder/1",
+			Type:    "rsa",
+			Bits:    4096,
+			Rounds:  1,
+			Time:    1,
+			Memory:  512,
+			Threads: 4,
+		},
+	}
+
+	for _, card := range cards {
+		decoded, err := Decode(card.Encode())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(card, decoded) {
+			t.Fatalf("round trip mismatch:\n%+v\n%+v", card, decoded)
+		}
+	}
+
+	if _, err := Decode("ssh-ed25519 AAAA"); err != ErrInvalidEncoding {
+		t.Fatalf("expected ErrInvalidEncoding, got %v", err)
+	}
+
+	if _, err := Decode(Prefix + "s=slowseeder/1&t=rsa&b=lots"); err == nil {
+		t.Fatal("expected error for invalid number")
+	}
+
+}
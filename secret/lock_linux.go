@@ -0,0 +1,10 @@
+package secret
+
+import "golang.org/x/sys/unix"
+
+// LockMemory locks all current and future memory of the process into
+// RAM, keeping secrets out of swap. It may fail if the memlock limit is
+// lower than the memory used by Argon2.
+func LockMemory() error {
+	return unix.Mlockall(unix.MCL_CURRENT | unix.MCL_FUTURE)
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package secret
+
+// LockMemory is not supported on this platform and always returns ErrLockNotSupported
+func LockMemory() error {
+	return ErrLockNotSupported
+}
@@ -0,0 +1,37 @@
+// Package secret provides best effort protection of secret material held
+// in memory.
+//
+// Go offers no guarantee that the runtime has not copied a buffer, and
+// strings can not be cleared at all, so wiping only shortens the time a
+// secret lingers. Locking memory keeps secrets from being written to swap.
+package secret
+
+import (
+	"errors"
+	"math/big"
+)
+
+// ErrLockNotSupported is the error returned when memory can not be locked on this platform
+var ErrLockNotSupported = errors.New("locking memory is only supported on Linux")
+
+// Wipe overwrites b with zeros
+func Wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// WipeInt overwrites the digits of n with zeros and sets it to zero
+func WipeInt(n *big.Int) {
+
+	if n == nil {
+		return
+	}
+
+	words := n.Bits()
+	for i := range words {
+		words[i] = 0
+	}
+	n.SetInt64(0)
+
+}
@@ -0,0 +1,31 @@
+package secret
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+)
+
+func TestWipe(t *testing.T) {
+
+	b := []byte("correct horse battery staple")
+	Wipe(b)
+	if !bytes.Equal(b, make([]byte, len(b))) {
+		t.Fatalf("buffer not wiped: %q", b)
+	}
+
+	n, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	words := n.Bits()
+	WipeInt(n)
+	if n.Sign() != 0 {
+		t.Fatalf("integer not wiped: %v", n)
+	}
+	for _, w := range words {
+		if w != 0 {
+			t.Fatal("integer digits not wiped")
+		}
+	}
+
+	WipeInt(nil)
+
+}
@@ -0,0 +1,94 @@
+// Package seedphrase canonicalizes seedphrases before key derivation.
+//
+// The same phrase can reach ssh-keydgen as different bytes: keyboards
+// and operating systems disagree on Unicode normalization, and piped
+// input usually ends with a newline. Canonicalization normalizes the
+// phrase to a single Unicode form, trims surrounding whitespace and
+// collapses internal whitespace to single spaces, so that every way of
+// typing a phrase derives the same key. Seeds that are not valid UTF-8,
+// such as random bytes in a seed file, can not have been typed and are
+// left untouched.
+package seedphrase
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Form represents a canonicalization form
+type Form string
+
+// These constants represent the supported forms
+const (
+	// Raw leaves the seedphrase untouched, matching releases before canonicalization was added
+	Raw Form = "raw"
+
+	// NFC applies Unicode canonical composition
+	NFC Form = "nfc"
+
+	// NFKD applies Unicode compatibility decomposition, as BIP-39 does
+	NFKD Form = "nfkd"
+)
+
+// Version must change whenever Canonicalize would produce different
+// output for the same input
+const Version = 1
+
+// ErrUnsupportedForm is the error returned when an unknown form is requested
+var ErrUnsupportedForm = errors.New("unsupported seedphrase normalization, use nfkd, nfc or raw")
+
+// ParseForm returns the Form named by s
+func ParseForm(s string) (Form, error) {
+	switch f := Form(strings.ToLower(s)); f {
+	case Raw, NFC, NFKD:
+		return f, nil
+	}
+	return "", ErrUnsupportedForm
+}
+
+// ParseScheme returns the Form identified by a string created with Form.Scheme
+func ParseScheme(scheme string) (Form, error) {
+	if scheme == "" {
+		return Raw, nil
+	}
+	for _, f := range []Form{NFC, NFKD} {
+		if f.Scheme() == scheme {
+			return f, nil
+		}
+	}
+	return "", ErrUnsupportedForm
+}
+
+// Scheme identifies the form and canonicalization version as part of a
+// derivation scheme. Raw has no identifier, as it predates versioning.
+func (f Form) Scheme() string {
+	if f == Raw {
+		return ""
+	}
+	return string(f) + "/" + strconv.Itoa(Version)
+}
+
+// Canonicalize returns the canonical form of phrase, or phrase itself if
+// it is binary rather than text
+func Canonicalize(phrase []byte, f Form) []byte {
+
+	if !utf8.Valid(phrase) {
+		return phrase
+	}
+
+	switch f {
+	case NFC:
+		phrase = norm.NFC.Bytes(phrase)
+	case NFKD:
+		phrase = norm.NFKD.Bytes(phrase)
+	default:
+		return phrase
+	}
+
+	return []byte(strings.Join(strings.Fields(string(phrase)), " "))
+
+}
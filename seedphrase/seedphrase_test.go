@@ -0,0 +1,54 @@
+package seedphrase
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCanonicalize(t *testing.T) {
+
+	var (
+		composed   = []byte("caf\u00e9 cr\u00e8me")
+		decomposed = []byte("cafe\u0301 cre\u0300me")
+	)
+
+	cases := []struct {
+		form     Form
+		input    []byte
+		expected []byte
+	}{
+		{NFC, decomposed, composed},
+		{NFC, composed, composed},
+		{NFKD, composed, decomposed},
+		{NFKD, []byte("  correct\thorse  battery\n\nstaple\n"), []byte("correct horse battery staple")},
+		{NFKD, []byte("\ufb01ve"), []byte("five")},
+		{Raw, []byte(" hunter2\n"), []byte(" hunter2\n")},
+		{NFKD, []byte("\xff\x00 \n\n\xfe"), []byte("\xff\x00 \n\n\xfe")},
+	}
+
+	for _, c := range cases {
+		if out := Canonicalize(c.input, c.form); !bytes.Equal(out, c.expected) {
+			t.Errorf("%s(%q): expected %q, got %q", c.form, c.input, c.expected, out)
+		}
+	}
+
+}
+
+func TestScheme(t *testing.T) {
+
+	for _, f := range []Form{Raw, NFC, NFKD} {
+		parsed, err := ParseScheme(f.Scheme())
+		if err != nil || parsed != f {
+			t.Fatalf("%s: round trip returned %q, %v", f, parsed, err)
+		}
+	}
+
+	if Raw.Scheme() != "" || NFKD.Scheme() != "nfkd/1" {
+		t.Fatalf("unexpected schemes %q, %q", Raw.Scheme(), NFKD.Scheme())
+	}
+
+	if _, err := ParseForm("nfd"); err != ErrUnsupportedForm {
+		t.Fatalf("expected ErrUnsupportedForm, got %v", err)
+	}
+
+}
@@ -0,0 +1,228 @@
+// Package shamir implements Shamir's secret sharing over GF(256).
+//
+// A secret is split into a set of shares such that any threshold of
+// them reconstructs it, while fewer reveal nothing about it. Every
+// share carries a share-set identifier, so shares from different splits
+// are never mixed, and a checksum that catches transcription errors.
+package shamir
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+)
+
+// Prefix begins every encoded share
+const Prefix = "ssh-keydgen-share:"
+
+const (
+	version      = 1
+	headerSize   = 7
+	checksumSize = 4
+)
+
+var (
+	// ErrInvalidParameters is the error returned when splitting with an impossible threshold or share count
+	ErrInvalidParameters = errors.New("threshold must be at least 2 and no more than the number of shares, which can not exceed 255")
+
+	// ErrInvalidShare is the error returned when a share can not be decoded
+	ErrInvalidShare = errors.New("share is not a valid ssh-keydgen share")
+
+	// ErrInvalidChecksum is the error returned when a share has been mistyped or damaged
+	ErrInvalidChecksum = errors.New("share checksum is invalid, check for typos")
+
+	// ErrMixedShares is the error returned when combining shares from different splits
+	ErrMixedShares = errors.New("shares belong to different share sets")
+
+	// ErrDuplicateShare is the error returned when the same share is supplied twice
+	ErrDuplicateShare = errors.New("the same share was supplied more than once")
+
+	// ErrNotEnoughShares is the error returned when fewer shares than the threshold are combined
+	ErrNotEnoughShares = errors.New("not enough shares to reconstruct the secret")
+)
+
+// Share represents one share of a split secret
+type Share struct {
+	SetID     [4]byte
+	Threshold byte
+	Index     byte
+	Data      []byte
+}
+
+// Split divides secret into n shares, any k of which reconstruct it.
+// Polynomial coefficients and the share-set identifier are read from rand.
+func Split(secret []byte, k, n int, rand io.Reader) ([]*Share, error) {
+
+	if k < 2 || k > n || n > 255 {
+		return nil, ErrInvalidParameters
+	}
+
+	if len(secret) == 0 {
+		return nil, errors.New("secret can not be empty")
+	}
+
+	var setID [4]byte
+	if _, err := io.ReadFull(rand, setID[:]); err != nil {
+		return nil, err
+	}
+
+	shares := make([]*Share, n)
+	for i := range shares {
+		shares[i] = &Share{
+			SetID:     setID,
+			Threshold: byte(k),
+			Index:     byte(i + 1),
+			Data:      make([]byte, len(secret)),
+		}
+	}
+
+	coefficients := make([]byte, k)
+	for i, b := range secret {
+
+		coefficients[0] = b
+		if _, err := io.ReadFull(rand, coefficients[1:]); err != nil {
+			return nil, err
+		}
+
+		for _, s := range shares {
+			s.Data[i] = evaluate(coefficients, s.Index)
+		}
+
+	}
+
+	for i := range coefficients {
+		coefficients[i] = 0
+	}
+
+	return shares, nil
+
+}
+
+// Combine reconstructs the secret from at least threshold shares of the same set
+func Combine(shares []*Share) ([]byte, error) {
+
+	if len(shares) == 0 {
+		return nil, ErrNotEnoughShares
+	}
+
+	first := shares[0]
+	seen := map[byte]bool{}
+	for _, s := range shares {
+		if s.SetID != first.SetID || s.Threshold != first.Threshold || len(s.Data) != len(first.Data) {
+			return nil, ErrMixedShares
+		}
+		if seen[s.Index] {
+			return nil, ErrDuplicateShare
+		}
+		seen[s.Index] = true
+	}
+
+	if len(shares) < int(first.Threshold) {
+		return nil, ErrNotEnoughShares
+	}
+	shares = shares[:first.Threshold]
+
+	secret := make([]byte, len(first.Data))
+	for i, s := range shares {
+
+		// Lagrange basis polynomial for this share evaluated at zero
+		basis := byte(1)
+		for j, o := range shares {
+			if i != j {
+				basis = mul(basis, div(o.Index, o.Index^s.Index))
+			}
+		}
+
+		for b := range secret {
+			secret[b] ^= mul(s.Data[b], basis)
+		}
+
+	}
+
+	return secret, nil
+
+}
+
+// String encodes the share as text
+func (s *Share) String() string {
+
+	buf := bytes.NewBuffer(nil)
+	buf.WriteByte(version)
+	buf.Write(s.SetID[:])
+	buf.WriteByte(s.Threshold)
+	buf.WriteByte(s.Index)
+	buf.Write(s.Data)
+
+	sum := sha256.Sum256(buf.Bytes())
+	buf.Write(sum[:checksumSize])
+
+	return Prefix + hex.EncodeToString(buf.Bytes())
+
+}
+
+// Parse decodes a share encoded with Share.String
+func Parse(s string) (*Share, error) {
+
+	s = strings.ToLower(strings.TrimSpace(s))
+	if !strings.HasPrefix(s, Prefix) {
+		return nil, ErrInvalidShare
+	}
+
+	b, err := hex.DecodeString(strings.TrimPrefix(s, Prefix))
+	if err != nil || len(b) <= headerSize+checksumSize {
+		return nil, ErrInvalidShare
+	}
+
+	payload, checksum := b[:len(b)-checksumSize], b[len(b)-checksumSize:]
+	if sum := sha256.Sum256(payload); !bytes.Equal(sum[:checksumSize], checksum) {
+		return nil, ErrInvalidChecksum
+	}
+
+	// Split never creates shares below a threshold of two, and Combine
+	// would return a secret of zeros for them
+	if payload[0] != version || payload[5] < 2 || payload[6] == 0 {
+		return nil, ErrInvalidShare
+	}
+
+	share := &Share{
+		Threshold: payload[5],
+		Index:     payload[6],
+		Data:      payload[headerSize:],
+	}
+	copy(share.SetID[:], payload[1:5])
+
+	return share, nil
+
+}
+
+// evaluate returns the value of the polynomial with the given coefficients at x
+func evaluate(coefficients []byte, x byte) byte {
+	var result byte
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		result = mul(result, x) ^ coefficients[i]
+	}
+	return result
+}
+
+// mul multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x + 1, without branching on secret data
+func mul(a, b byte) byte {
+	var result byte
+	for i := 0; i < 8; i++ {
+		result ^= a & -(b & 1)
+		b >>= 1
+		a = (a << 1) ^ (0x1b & -(a >> 7))
+	}
+	return result
+}
+
+// div divides in GF(2^8), using a^254 as the inverse of a
+func div(a, b byte) byte {
+	inverse := b
+	for i := 0; i < 6; i++ {
+		inverse = mul(mul(inverse, inverse), b)
+	}
+	return mul(a, mul(inverse, inverse))
+}
@@ -0,0 +1,103 @@
+package shamir
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+func TestSplitCombine(t *testing.T) {
+
+	secret := []byte("legal winner thank year wave sausage worth useful legal winner thank yellow")
+
+	shares, err := Split(secret, 3, 5, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subsets := [][]int{{0, 1, 2}, {4, 2, 0}, {1, 3, 4}, {0, 1, 2, 3, 4}}
+	for _, subset := range subsets {
+
+		var selected []*Share
+		for _, i := range subset {
+			parsed, err := Parse(shares[i].String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			selected = append(selected, parsed)
+		}
+
+		combined, err := Combine(selected)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(combined, secret) {
+			t.Fatalf("shares %v reconstructed %q", subset, combined)
+		}
+
+	}
+
+	if _, err = Combine(shares[:2]); err != ErrNotEnoughShares {
+		t.Fatalf("expected ErrNotEnoughShares, got %v", err)
+	}
+
+	if _, err = Combine([]*Share{shares[0], shares[1], shares[1]}); err != ErrDuplicateShare {
+		t.Fatalf("expected ErrDuplicateShare, got %v", err)
+	}
+
+	others, err := Split(secret, 3, 5, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Combine([]*Share{shares[0], shares[1], others[2]}); err != ErrMixedShares {
+		t.Fatalf("expected ErrMixedShares, got %v", err)
+	}
+
+}
+
+func TestParse(t *testing.T) {
+
+	shares, err := Split([]byte("secret"), 2, 2, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encoded := []byte(shares[0].String())
+	last := len(encoded) - 1
+	if encoded[last] == '0' {
+		encoded[last] = '1'
+	} else {
+		encoded[last] = '0'
+	}
+
+	if _, err = Parse(string(encoded)); err != ErrInvalidChecksum {
+		t.Fatalf("expected ErrInvalidChecksum, got %v", err)
+	}
+
+	if _, err = Parse("ssh-ed25519 AAAA"); err != ErrInvalidShare {
+		t.Fatalf("expected ErrInvalidShare, got %v", err)
+	}
+
+	for _, threshold := range []byte{0, 1} {
+		share := *shares[0]
+		share.Threshold = threshold
+		if _, err = Parse(share.String()); err != ErrInvalidShare {
+			t.Fatalf("expected ErrInvalidShare for threshold %d, got %v", threshold, err)
+		}
+	}
+
+}
+
+func TestField(t *testing.T) {
+
+	if p := mul(0x53, 0xca); p != 0x01 {
+		t.Fatalf("expected 0x53 * 0xca = 0x01, got %#x", p)
+	}
+
+	for a := 1; a < 256; a++ {
+		if q := div(1, byte(a)); mul(q, byte(a)) != 1 {
+			t.Fatalf("bad inverse for %#x", a)
+		}
+	}
+
+}
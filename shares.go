@@ -0,0 +1,138 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/cornfeedhobo/ssh-keydgen/secret"
+	"github.com/cornfeedhobo/ssh-keydgen/shamir"
+	"gopkg.in/urfave/cli.v1"
+)
+
+func splitAction(ctx *cli.Context) error {
+
+	parent := ctx.Parent()
+
+	if err := lockMemory(parent); err != nil {
+		return err
+	}
+
+	if err := applyProfile(parent); err != nil {
+		return err
+	}
+
+	if _, err := seedphraseForm(parent); err != nil {
+		return err
+	}
+
+	seed, err := getSeedphrase(parent)
+	if err != nil {
+		return err
+	}
+	defer secret.Wipe(seed)
+
+	shares, err := shamir.Split(seed, ctx.Int("k"), ctx.Int("n"), rand.Reader)
+	if err != nil {
+		return newError("Error splitting seedphrase: " + err.Error())
+	}
+
+	fmt.Fprintf(stdout, "\nShare set %x, any %d of these %d shares reconstruct the seedphrase:\n\n", shares[0].SetID, ctx.Int("k"), len(shares))
+	for _, share := range shares {
+		fmt.Fprintf(stdout, "Share %d: %s\n", share.Index, share)
+	}
+
+	if res := currentResult(ctx); res != nil {
+		res.Shares = &sharesResult{SetID: fmt.Sprintf("%x", shares[0].SetID), Threshold: ctx.Int("k")}
+		for _, share := range shares {
+			res.Shares.Shares = append(res.Shares.Shares, share.String())
+		}
+	}
+
+	return nil
+
+}
+
+func combineAction(ctx *cli.Context) error {
+
+	shares, err := getShares(ctx)
+	if err != nil {
+		return err
+	}
+
+	return generate(ctx.Parent(), func(parent *cli.Context) ([]byte, error) {
+
+		secret, err := shamir.Combine(shares)
+		if err != nil {
+			return nil, newErrorKind(kindSeedphrase, "Error combining shares: "+err.Error())
+		}
+
+		// the secret was canonicalized before it was split, and may be binary
+		return checkSeedphrase(parent, secret, false)
+
+	})
+
+}
+
+// getShares reads shares from the arguments, or else every line of stdin
+// when piped, or else prompts until the threshold is reached
+func getShares(ctx *cli.Context) ([]*shamir.Share, error) {
+
+	var (
+		shares []*shamir.Share
+		lines  = []string(ctx.Args())
+	)
+
+	interactive := len(lines) == 0 && isTerminal()
+
+	if len(lines) == 0 && !interactive {
+		b, err := ioutil.ReadAll(stdin)
+		if err != nil {
+			return nil, newErrorKind(kindIO, err.Error())
+		}
+		lines = strings.Split(string(b), "\n")
+	}
+
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		share, err := shamir.Parse(line)
+		if err != nil {
+			return nil, newErrorKind(kindSeedphrase, "Error reading share "+strconv.Itoa(len(shares)+1)+": "+err.Error())
+		}
+		shares = append(shares, share)
+	}
+
+	if !interactive {
+		return shares, nil
+	}
+
+	for len(shares) == 0 || len(shares) < int(shares[0].Threshold) {
+
+		if len(shares) == 0 {
+			fmt.Fprint(stdout, "Enter share 1: ")
+		} else {
+			fmt.Fprintf(stdout, "Enter share %d of %d: ", len(shares)+1, shares[0].Threshold)
+		}
+
+		line, err := stdin.ReadString('\n')
+		if err != nil {
+			return nil, newErrorKind(kindIO, err.Error())
+		}
+
+		share, err := shamir.Parse(line)
+		if err != nil {
+			fmt.Fprint(stdout, "\nerror: "+err.Error()+"\n\n")
+			continue
+		}
+
+		shares = append(shares, share)
+
+	}
+
+	return shares, nil
+
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"crypto"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/cornfeedhobo/ssh-keydgen/keygen"
+	"github.com/cornfeedhobo/ssh-keydgen/secret"
+	"github.com/cornfeedhobo/ssh-keydgen/sshsig"
+	"golang.org/x/crypto/ssh"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// signAction derives the key and signs each file given, or else stdin,
+// without writing the private key anywhere
+func signAction(ctx *cli.Context) error {
+
+	parent := ctx.Parent()
+
+	if err := lockMemory(parent); err != nil {
+		return err
+	}
+
+	imported, err := applyImport(parent)
+	if err != nil {
+		return err
+	}
+
+	if err = applyProfile(parent); err != nil {
+		return err
+	}
+
+	if err = checkImportedKeyfile(parent, imported); err != nil {
+		return err
+	}
+
+	parent.Set("t", strings.ToLower(parent.String("t")))
+
+	if _, err = seedphraseForm(parent); err != nil {
+		return err
+	}
+
+	namespace := ctx.String("n")
+	if namespace == "" {
+		return newErrorKind(kindUsage, "A namespace is required, use -n, for example -n git or -n file")
+	}
+
+	keyType := parent.String("t")
+	if keyType == keygen.DSA || !keygen.SSHKeyType(keyType) {
+		return newErrorKind(kindUsage, "Key type "+keyType+" can not make SSH signatures")
+	}
+
+	files := []string(ctx.Args())
+	if len(files) == 0 && !hasSeedSource(parent) {
+		return newErrorKind(kindUsage, "Signing stdin needs the seedphrase from --seed-file, --seed-fd, --seed-env or --as")
+	}
+
+	// the signature of stdin is all that goes to stdout
+	if len(files) == 0 {
+		stdout = os.Stderr
+	}
+
+	for _, file := range files {
+		if _, err = os.Stat(file); err != nil {
+			return newErrorKind(kindIO, err.Error())
+		}
+		if err = checkNotSymlink(file + ".sig"); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintln(stdout, "Signing with "+keyType+" key")
+
+	seedphrase, err := readSeedphrase(parent, getSeedphrase)
+	if err != nil {
+		return err
+	}
+	defer secret.Wipe(seedphrase)
+
+	keydgen, privateKey, err := deriveKey(parent, seedphrase)
+	if err != nil {
+		return err
+	}
+	defer keydgen.Wipe()
+
+	if imported != nil && imported.Fingerprint != "" {
+		if err = verifyFingerprint(keydgen, imported.Fingerprint); err != nil {
+			return err
+		}
+	}
+
+	signer, ok := privateKey.(crypto.Signer)
+	if !ok {
+		return newBug("Generated " + keyType + " key can not sign")
+	}
+
+	if len(files) == 0 {
+		return signStdin(ctx, keydgen, signer, namespace)
+	}
+
+	var written []string
+	for _, file := range files {
+
+		signature, err := signFile(signer, namespace, file)
+		if err != nil {
+			return err
+		}
+
+		if err = replaceFile(file+".sig", signature, 0644); err != nil {
+			return newErrorKind(kindIO, "Error writing signature: "+err.Error())
+		}
+		fmt.Fprintln(stdout, "Signature written to "+file+".sig")
+
+		written = append(written, file+".sig")
+		recordSignature(ctx, &signatureResult{File: file, Namespace: namespace, Signature: string(signature)})
+
+	}
+
+	return recordKey(parent, keydgen, parent.String("al"), written, false)
+
+}
+
+// signStdin writes the signature of stdin to stdout, or only to the result
+// with --output json
+func signStdin(ctx *cli.Context, k *keygen.Keydgen, signer crypto.Signer, namespace string) error {
+
+	signature, err := sshsig.Sign(signer, namespace, stdin)
+	if err != nil {
+		return newError("Error signing stdin: " + err.Error())
+	}
+
+	if currentResult(ctx) == nil {
+		os.Stdout.Write(signature)
+	}
+	recordSignature(ctx, &signatureResult{Namespace: namespace, Signature: string(signature)})
+
+	return recordKey(ctx.Parent(), k, ctx.Parent().String("al"), nil, false)
+
+}
+
+func signFile(signer crypto.Signer, namespace, file string) ([]byte, error) {
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, newErrorKind(kindIO, err.Error())
+	}
+	defer f.Close()
+
+	signature, err := sshsig.Sign(signer, namespace, f)
+	if err != nil {
+		return nil, newError("Error signing " + file + ": " + err.Error())
+	}
+
+	return signature, nil
+
+}
+
+// verifyAction checks the signature of a file, or else stdin, and that it
+// was made by one of the trusted keys when given
+func verifyAction(ctx *cli.Context) error {
+
+	namespace := ctx.String("n")
+	if namespace == "" {
+		return newErrorKind(kindUsage, "A namespace is required, use -n")
+	}
+
+	if len(ctx.Args()) > 1 {
+		return newErrorKind(kindUsage, "Only one file can be verified at a time")
+	}
+
+	file := ctx.Args().First()
+	signatureFile := ctx.String("s")
+	if signatureFile == "" && file != "" {
+		signatureFile = file + ".sig"
+	}
+	if signatureFile == "" {
+		return newErrorKind(kindUsage, "A signature file is required when verifying stdin, use -s")
+	}
+
+	signature, err := ioutil.ReadFile(signatureFile)
+	if err != nil {
+		return newErrorKind(kindIO, "Error reading signature: "+err.Error())
+	}
+
+	var trusted []ssh.PublicKey
+	if ctx.String("key") != "" {
+		if trusted, err = readPublicKeys(ctx.String("key")); err != nil {
+			return err
+		}
+	}
+
+	var message io.Reader = stdin
+	if file != "" {
+		f, err := os.Open(file)
+		if err != nil {
+			return newErrorKind(kindIO, err.Error())
+		}
+		defer f.Close()
+		message = f
+	}
+
+	pub, err := sshsig.Verify(signature, namespace, message)
+	if err != nil {
+		return newErrorKind(kindSignature, "Bad signature: "+err.Error())
+	}
+
+	fingerprint := ssh.FingerprintSHA256(pub)
+
+	if trusted != nil && !containsKey(trusted, pub) {
+		return newErrorKind(kindSignature, "Signature was made by "+fingerprint+", which is not in "+ctx.String("key"))
+	}
+
+	fmt.Fprintln(stdout, "Good \""+namespace+"\" signature with "+pub.Type()+" key "+fingerprint)
+	if trusted == nil {
+		fmt.Fprintln(stdout, "warning: the key was not checked, use --key to require a trusted key")
+	}
+
+	recordSignature(ctx, &signatureResult{File: file, Namespace: namespace, Fingerprint: fingerprint})
+
+	return nil
+
+}
+
+// readPublicKeys returns every key in an authorized_keys or .pub file
+func readPublicKeys(filename string) ([]ssh.PublicKey, error) {
+
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, newErrorKind(kindIO, "Error reading keys: "+err.Error())
+	}
+
+	var keys []ssh.PublicKey
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+		if err != nil {
+			return nil, newErrorKind(kindUsage, "Error reading keys from "+filename+": "+err.Error())
+		}
+		keys = append(keys, pub)
+	}
+
+	if len(keys) == 0 {
+		return nil, newErrorKind(kindUsage, "No keys found in "+filename)
+	}
+
+	return keys, nil
+
+}
+
+func containsKey(keys []ssh.PublicKey, pub ssh.PublicKey) bool {
+
+	for _, key := range keys {
+		if string(key.Marshal()) == string(pub.Marshal()) {
+			return true
+		}
+	}
+
+	return false
+
+}
+
+// hasSeedSource reports whether the seedphrase comes from somewhere other
+// than stdin or the terminal
+func hasSeedSource(ctx *cli.Context) bool {
+	return ctx.IsSet("seed-file") || ctx.IsSet("seed-fd") || ctx.IsSet("seed-env") || ctx.String("as") != ""
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cornfeedhobo/ssh-keydgen/keygen"
+	"github.com/cornfeedhobo/ssh-keydgen/secret"
+	"github.com/mitchellh/go-homedir"
+	"golang.org/x/crypto/ssh"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// signerEntry describes one member of an allowed signers manifest, whose
+// keys are either derived from the seedphrase under Label or read from
+// PublicKeyFile. Options left out fall back to the flags, while empty
+// Namespaces allow every namespace.
+type signerEntry struct {
+	Principals    string  `json:"principals"`
+	Label         string  `json:"label"`
+	Type          string  `json:"type"`
+	Bits          int     `json:"bits"`
+	Curve         int     `json:"curve"`
+	PublicKeyFile string  `json:"public_key_file"`
+	Namespaces    *string `json:"namespaces"`
+	ValidAfter    string  `json:"valid_after"`
+	ValidBefore   string  `json:"valid_before"`
+}
+
+// allowedSignersAction writes an allowed signers file, as read by
+// ssh-keygen -Y verify and git, for every member of a manifest
+func allowedSignersAction(ctx *cli.Context) error {
+
+	parent := ctx.Parent()
+
+	if ctx.NArg() != 1 {
+		return newErrorKind(kindUsage, "A manifest file is required")
+	}
+
+	jobs := ctx.Int("jobs")
+	if jobs < 1 {
+		return newErrorKind(kindUsage, "At least one job is required")
+	}
+
+	if err := lockMemory(parent); err != nil {
+		return err
+	}
+
+	if err := applyProfile(parent); err != nil {
+		return err
+	}
+
+	parent.Set("t", strings.ToLower(parent.String("t")))
+
+	if _, err := seedphraseForm(parent); err != nil {
+		return err
+	}
+
+	entries, err := readSignersManifest(ctx, ctx.Args().First())
+	if err != nil {
+		return err
+	}
+
+	// the file is the only thing on stdout unless written elsewhere
+	if ctx.String("o") == "" && currentResult(ctx) == nil {
+		stdout = os.Stderr
+	}
+
+	var derived []manifestEntry
+	for _, entry := range entries {
+		if entry.Label != "" {
+			derived = append(derived, manifestEntry{
+				Label: entry.Label,
+				Type:  entry.Type,
+				Bits:  entry.Bits,
+				Curve: entry.Curve,
+			})
+		}
+	}
+
+	var keys []*keygen.Keydgen
+	if len(derived) > 0 {
+
+		if jobs > len(derived) {
+			jobs = len(derived)
+		}
+
+		fmt.Fprintf(stdout, "Deriving %d public keys, %d at a time\n", len(derived), jobs)
+
+		seed, err := readSeedphrase(parent, getSeedphrase)
+		if err != nil {
+			return err
+		}
+		defer secret.Wipe(seed)
+
+		keys, err = generateBatch(&derivation{
+			seed:    seed,
+			rounds:  uint32(parent.Int("a")),
+			time:    uint32(parent.Uint("at")),
+			memory:  uint32(parent.Uint("am")),
+			threads: uint8(parent.Uint("ap")),
+		}, derived, jobs)
+		if err != nil {
+			return err
+		}
+		defer wipeKeys(keys)
+
+	}
+
+	buf := bytes.NewBuffer(nil)
+	for _, entry := range entries {
+
+		var publicKeys []ssh.PublicKey
+		if entry.Label != "" {
+
+			k := keys[0]
+			keys = keys[1:]
+
+			pub, err := k.PublicKey()
+			if err != nil {
+				return newError(err.Error())
+			}
+			publicKeys = append(publicKeys, pub)
+
+			if err = recordKey(parent, k, entry.Label, nil, false); err != nil {
+				return err
+			}
+
+		} else if publicKeys, err = readPublicKeys(entry.PublicKeyFile); err != nil {
+			return err
+		}
+
+		for _, pub := range publicKeys {
+			fmt.Fprintf(buf, "%s %s%s", entry.Principals, signerOptions(entry), ssh.MarshalAuthorizedKey(pub))
+		}
+
+	}
+
+	if filename := ctx.String("o"); filename != "" {
+		if err = replaceFile(filename, buf.Bytes(), 0644); err != nil {
+			return newErrorKind(kindIO, "Error writing allowed signers: "+err.Error())
+		}
+		fmt.Fprintln(stdout, "Allowed signers written to "+filename)
+	} else if res := currentResult(ctx); res != nil {
+		res.AllowedSigners = buf.String()
+	} else {
+		os.Stdout.Write(buf.Bytes())
+	}
+
+	return nil
+
+}
+
+// readSignersManifest reads and checks the manifest, filling in what
+// entries leave out from the flags
+func readSignersManifest(ctx *cli.Context, filename string) ([]signerEntry, error) {
+
+	parent := ctx.Parent()
+
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, newErrorKind(kindIO, "Error reading manifest: "+err.Error())
+	}
+
+	var entries []signerEntry
+	if err = json.Unmarshal(b, &entries); err != nil {
+		return nil, newErrorKind(kindUsage, "Error reading manifest: "+err.Error())
+	}
+
+	if len(entries) == 0 {
+		return nil, newErrorKind(kindUsage, "Manifest lists no signers")
+	}
+
+	labels := map[string]bool{}
+
+	for i := range entries {
+
+		entry := &entries[i]
+		where := "Manifest entry " + strconv.Itoa(i+1)
+
+		if entry.Principals == "" {
+			return nil, newErrorKind(kindUsage, where+" has no principals")
+		}
+		if strings.ContainsAny(entry.Principals, " \t\"") {
+			return nil, newErrorKind(kindUsage, where+" has principals with spaces or quotes, separate principals with commas")
+		}
+
+		if (entry.Label == "") == (entry.PublicKeyFile == "") {
+			return nil, newErrorKind(kindUsage, where+" needs either a label or a public_key_file")
+		}
+
+		if entry.PublicKeyFile != "" {
+			if entry.PublicKeyFile, err = homedir.Expand(entry.PublicKeyFile); err != nil {
+				return nil, newErrorKind(kindUsage, where+": "+err.Error())
+			}
+		} else {
+
+			if labels[entry.Label] {
+				return nil, newErrorKind(kindUsage, where+" repeats the label "+entry.Label+", which would derive the same key")
+			}
+			labels[entry.Label] = true
+
+			if entry.Type == "" {
+				entry.Type = parent.String("t")
+			}
+			entry.Type = strings.ToLower(entry.Type)
+			if entry.Type == keygen.DSA || !keygen.SSHKeyType(entry.Type) {
+				return nil, newErrorKind(kindUsage, "Key type "+entry.Type+" can not make SSH signatures: "+entry.Label)
+			}
+			if entry.Bits == 0 {
+				entry.Bits = parent.Int("b")
+			}
+			if entry.Curve == 0 {
+				entry.Curve = parent.Int("c")
+			}
+
+		}
+
+		if entry.Namespaces == nil {
+			namespaces := ctx.String("namespaces")
+			entry.Namespaces = &namespaces
+		}
+		if strings.ContainsAny(*entry.Namespaces, " \t\"") {
+			return nil, newErrorKind(kindUsage, where+" has namespaces with spaces or quotes, separate namespaces with commas")
+		}
+
+		if entry.ValidAfter == "" {
+			entry.ValidAfter = ctx.String("valid-after")
+		}
+		if entry.ValidBefore == "" {
+			entry.ValidBefore = ctx.String("valid-before")
+		}
+
+		var after, before time.Time
+		if entry.ValidAfter != "" {
+			if after, err = parseDate(entry.ValidAfter); err != nil {
+				return nil, err
+			}
+			entry.ValidAfter = signerTime(after)
+		}
+		if entry.ValidBefore != "" {
+			if before, err = parseDate(entry.ValidBefore); err != nil {
+				return nil, err
+			}
+			entry.ValidBefore = signerTime(before)
+		}
+		if !after.IsZero() && !before.IsZero() && !before.After(after) {
+			return nil, newErrorKind(kindUsage, where+" is valid before it is valid after")
+		}
+
+	}
+
+	return entries, nil
+
+}
+
+// signerOptions returns the options of an allowed signers line, followed
+// by a space when there are any
+func signerOptions(entry signerEntry) string {
+
+	var options []string
+	if entry.Namespaces != nil && *entry.Namespaces != "" {
+		options = append(options, "namespaces=\""+*entry.Namespaces+"\"")
+	}
+	if entry.ValidAfter != "" {
+		options = append(options, "valid-after=\""+entry.ValidAfter+"\"")
+	}
+	if entry.ValidBefore != "" {
+		options = append(options, "valid-before=\""+entry.ValidBefore+"\"")
+	}
+
+	if len(options) == 0 {
+		return ""
+	}
+
+	return strings.Join(options, ",") + " "
+
+}
+
+// signerTime formats t as the UTC timestamp of allowed signers files
+func signerTime(t time.Time) string {
+	return t.UTC().Format("20060102150405") + "Z"
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cornfeedhobo/ssh-keydgen/keygen"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestSignerOptions(t *testing.T) {
+
+	git, all := "git", ""
+	after := signerTime(time.Date(2026, 1, 1, 1, 0, 0, 0, time.FixedZone("CET", 3600)))
+
+	cases := []struct {
+		entry   signerEntry
+		options string
+	}{
+		{signerEntry{}, ""},
+		{signerEntry{Namespaces: &all}, ""},
+		{signerEntry{Namespaces: &git, ValidAfter: after}, `namespaces="git",valid-after="20260101000000Z" `},
+		{signerEntry{Namespaces: &all, ValidAfter: after, ValidBefore: "20270101000000Z"}, `valid-after="20260101000000Z",valid-before="20270101000000Z" `},
+	}
+
+	for _, c := range cases {
+		if options := signerOptions(c.entry); options != c.options {
+			t.Errorf("expected %q, got %q", c.options, options)
+		}
+	}
+
+}
+
+func TestAllowedSigners(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "ssh-keydgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// bob brings two keys of his own
+	var bob []ssh.PublicKey
+	for _, label := range []string{"bob-1", "bob-2"} {
+		k := deriveTestKey(t, label)
+		pub, err := k.PublicKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		bob = append(bob, pub)
+	}
+	bobFile := filepath.Join(dir, "bob.pub")
+	if err = ioutil.WriteFile(bobFile, append(ssh.MarshalAuthorizedKey(bob[0]), ssh.MarshalAuthorizedKey(bob[1])...), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest := filepath.Join(dir, "team.json")
+	if err = ioutil.WriteFile(manifest, []byte(`[
+  {"principals": "alice@example.com", "label": "alice"},
+  {"principals": "bob@example.com", "public_key_file": "`+bobFile+`", "valid_before": "2027-01-01"},
+  {"principals": "carol@example.com,ci@example.com", "label": "carol", "namespaces": ""}
+]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("SSH_KEYDGEN_TEST_SEED", "correct horse battery staple")
+	saved := stdout
+	defer func() { stdout = saved }()
+
+	output := filepath.Join(dir, "allowed_signers")
+	err = newApp().Run([]string{
+		"ssh-keydgen", "-t", "ed25519", "-a", "1000", "--at", "1", "--am", "512", "--ap", "1",
+		"--seed-env", "SSH_KEYDGEN_TEST_SEED",
+		"allowed-signers", "--valid-after", "2026-01-01", "-o", output, manifest,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// derived and listed keys keep the order of the manifest
+	line := func(principals, options string, pub ssh.PublicKey) string {
+		return principals + " " + options + string(ssh.MarshalAuthorizedKey(pub))
+	}
+	alice, err := deriveTestKey(t, "alice").PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	carol, err := deriveTestKey(t, "carol").PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := strings.Join([]string{
+		line("alice@example.com", `namespaces="git",valid-after="20260101000000Z" `, alice),
+		line("bob@example.com", `namespaces="git",valid-after="20260101000000Z",valid-before="20270101000000Z" `, bob[0]),
+		line("bob@example.com", `namespaces="git",valid-after="20260101000000Z",valid-before="20270101000000Z" `, bob[1]),
+		line("carol@example.com,ci@example.com", `valid-after="20260101000000Z" `, carol),
+	}, "")
+
+	if string(b) != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, b)
+	}
+
+}
+
+// deriveTestKey derives the ed25519 key of label as TestAllowedSigners does
+func deriveTestKey(t *testing.T, label string) *keygen.Keydgen {
+
+	d := &derivation{seed: []byte("correct horse battery staple"), rounds: 1000, time: 1, memory: 512, threads: 1}
+
+	k, err := generateEntry(context.Background(), d, manifestEntry{Label: label, Type: keygen.ED25519})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return k
+
+}
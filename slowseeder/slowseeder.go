@@ -9,28 +9,85 @@
 package slowseeder
 
 import (
+	"crypto/hmac"
 	"crypto/sha512"
 	"errors"
 	"io"
 	"sync"
+	"time"
 
+	"github.com/cornfeedhobo/ssh-keydgen/secret"
 	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/pbkdf2"
 	"golang.org/x/crypto/ripemd160"
 )
 
+// Scheme identifies the derivation performed by Reader, currently
+// PBKDF2-SHA512 and PBKDF2-RIPEMD160 feeding Argon2i. It must change
+// whenever a change to Reader would produce different output for the
+// same parameters.
+const Scheme = "slowseeder/1"
+
+// KeyfileScheme identifies the way MixKeyfile combines a seed and a keyfile
+const KeyfileScheme = "keyfile/1"
+
+// ErrClosed is the error returned when reading from a closed Reader
+var ErrClosed = errors.New("Reader has been closed")
+
 // Reader represents a drop in replacement for a rand source
 type Reader struct {
 	seed, salt, key      []byte
 	rounds, time, memory uint32
 	threads              uint8
 
-	mu    *sync.RWMutex
-	reads int
+	mu     *sync.RWMutex
+	reads  int
+	closed bool
+
+	progress func(Progress)
+	status   Progress
+	started  time.Time
+}
+
+// Progress describes the work done by a Reader so far
+type Progress struct {
+	// Bytes is the total number of bytes requested
+	Bytes int
+
+	// Invocations is the number of key derivations performed, one per Read
+	Invocations int
+
+	// Elapsed is the time since the first Read
+	Elapsed time.Duration
+}
+
+// MixKeyfile returns seed material requiring both seed and the contents
+// of keyfile, an HMAC-SHA512 of seed keyed with the SHA512 hash of keyfile.
+// Any file can serve as a keyfile, but it must never change.
+func MixKeyfile(seed []byte, keyfile io.Reader) ([]byte, error) {
+
+	h := sha512.New()
+	if _, err := io.Copy(h, keyfile); err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha512.New, h.Sum(nil))
+	mac.Write(seed)
+
+	return mac.Sum(nil), nil
+
 }
 
 // New returns a Reader generator suitable for use with cryptographic functions
-func New(seed []byte, rounds, time, memory uint32, threads uint8) (io.Reader, error) {
+func New(seed []byte, rounds, time, memory uint32, threads uint8) (*Reader, error) {
+	return NewWithLabel(seed, nil, rounds, time, memory, threads)
+}
+
+// NewWithLabel returns a Reader generator like New, using label as the
+// initial salt so that a single seed can derive many independent keys.
+// An empty label produces the same output as New. The seed and label are
+// copied, so the caller remains free to wipe its own.
+func NewWithLabel(seed, label []byte, rounds, time, memory uint32, threads uint8) (*Reader, error) {
 
 	var err error
 
@@ -55,7 +112,8 @@ func New(seed []byte, rounds, time, memory uint32, threads uint8) (io.Reader, er
 	}
 
 	return &Reader{
-		seed:    seed,
+		seed:    append([]byte(nil), seed...),
+		salt:    append([]byte(nil), label...),
 		rounds:  rounds,
 		time:    time,
 		memory:  memory,
@@ -70,9 +128,49 @@ func New(seed []byte, rounds, time, memory uint32, threads uint8) (io.Reader, er
 // generate the requested "entropy"
 func (r *Reader) Read(p []byte) (int, error) {
 	r.mu.Lock()
-	defer r.mu.Unlock()
-	r.seed = pbkdf2.Key(r.seed, r.key, int(r.rounds), sha512.Size, sha512.New)
-	r.salt = pbkdf2.Key(r.salt, r.key, int(r.reads), ripemd160.Size, ripemd160.New)
+	if r.closed {
+		r.mu.Unlock()
+		return 0, ErrClosed
+	}
+	if r.started.IsZero() {
+		r.started = time.Now()
+	}
+	seed := pbkdf2.Key(r.seed, r.key, int(r.rounds), sha512.Size, sha512.New)
+	salt := pbkdf2.Key(r.salt, r.key, int(r.reads), ripemd160.Size, ripemd160.New)
+	r.wipe()
+	r.seed, r.salt = seed, salt
 	r.key = argon2.Key(r.seed, r.salt, r.time, r.memory, r.threads, uint32(len(p)))
-	return copy(p, r.key), nil
+	n := copy(p, r.key)
+	r.status.Bytes += n
+	r.status.Invocations++
+	r.status.Elapsed = time.Since(r.started)
+	status, progress := r.status, r.progress
+	r.mu.Unlock()
+	if progress != nil {
+		progress(status)
+	}
+	return n, nil
+}
+
+// OnProgress sets fn to be called after every key derivation
+func (r *Reader) OnProgress(fn func(Progress)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.progress = fn
+}
+
+// Close zeroes the internal seed, salt and key, after which every Read fails
+func (r *Reader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.wipe()
+	r.seed, r.salt, r.key = nil, nil, nil
+	r.closed = true
+	return nil
+}
+
+func (r *Reader) wipe() {
+	secret.Wipe(r.seed)
+	secret.Wipe(r.salt)
+	secret.Wipe(r.key)
 }
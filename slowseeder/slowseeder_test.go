@@ -5,6 +5,7 @@ import (
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
+	"strings"
 )
 
 func Example_generateRSA() {
@@ -16,3 +17,33 @@ func Example_generateRSA() {
 	})
 	fmt.Println(string(e))
 }
+
+func ExampleMixKeyfile() {
+	keyfile := strings.NewReader("not a secret, but never changes")
+	seed, _ := MixKeyfile([]byte("slowseeder"), keyfile)
+	fmt.Printf("%x\n%x\n", seed[:32], seed[32:])
+	// Output:
+	// efa9cd939807c5e06bed3dedbdfbb73b03e04f674138d6b5eab9fb9fe2f4e4b2
+	// 44bcbba5aad45c8104811ad975d9d70d15e414474be40386fcef4298dc9618a6
+}
+
+func ExampleReader_Close() {
+	r, _ := New([]byte("slowseeder"), 1, 1, 512, 1)
+	r.Read(make([]byte, 32))
+	r.Close()
+	_, err := r.Read(make([]byte, 32))
+	fmt.Println(err)
+	// Output: Reader has been closed
+}
+
+func ExampleReader_OnProgress() {
+	r, _ := New([]byte("slowseeder"), 1, 1, 512, 1)
+	r.OnProgress(func(p Progress) {
+		fmt.Println(p.Invocations, p.Bytes)
+	})
+	r.Read(make([]byte, 32))
+	r.Read(make([]byte, 16))
+	// Output:
+	// 1 32
+	// 2 48
+}
@@ -0,0 +1,271 @@
+// Package sshsig implements the SSH signature format of OpenSSH, as made
+// and checked by ssh-keygen -Y sign and ssh-keygen -Y verify.
+//
+// A signature covers a hash of the message and a namespace, such as "git"
+// or "file", so a signature made for one purpose is never accepted for
+// another.
+package sshsig
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"hash"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	magic   = "SSHSIG"
+	version = 1
+
+	armorBegin = "-----BEGIN SSH SIGNATURE-----"
+	armorEnd   = "-----END SSH SIGNATURE-----"
+
+	// the line length of ssh-keygen
+	armorLine = 70
+)
+
+var (
+	// ErrMissingNamespace is the error returned when signing or verifying without a namespace
+	ErrMissingNamespace = errors.New("a namespace is required")
+
+	// ErrUnsupportedKey is the error returned when signing with a key other than Ed25519, ECDSA or RSA
+	ErrUnsupportedKey = errors.New("only ed25519, ecdsa and rsa keys can make SSH signatures")
+
+	// ErrMalformed is the error returned when a signature can not be decoded
+	ErrMalformed = errors.New("malformed SSH signature")
+
+	// ErrNamespaceMismatch is the error returned when a signature was made for another namespace
+	ErrNamespaceMismatch = errors.New("signature was made for a different namespace")
+
+	// ErrUnsupportedAlgorithm is the error returned for signatures using SHA-1 or an unknown hash
+	ErrUnsupportedAlgorithm = errors.New("unsupported signature algorithm")
+)
+
+// signature is the signature blob following the magic preamble
+type signature struct {
+	Version       uint32
+	PublicKey     []byte
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Signature     []byte
+}
+
+// signedData is the data signed by the key, following the magic preamble
+type signedData struct {
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Hash          []byte
+}
+
+// Sign returns the armored signature by key of message under namespace,
+// hashed with SHA-512 like ssh-keygen. RSA keys sign with rsa-sha2-512.
+func Sign(key crypto.Signer, namespace string, message io.Reader) ([]byte, error) {
+
+	if namespace == "" {
+		return nil, ErrMissingNamespace
+	}
+
+	pub, err := ssh.NewPublicKey(key.Public())
+	if err != nil {
+		return nil, ErrUnsupportedKey
+	}
+
+	data, err := signed(namespace, "sha512", message)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := sign(key, data)
+	if err != nil {
+		return nil, err
+	}
+
+	blob := append([]byte(magic), ssh.Marshal(signature{
+		Version:       version,
+		PublicKey:     pub.Marshal(),
+		Namespace:     namespace,
+		HashAlgorithm: "sha512",
+		Signature:     ssh.Marshal(sig),
+	})...)
+
+	return armor(blob), nil
+
+}
+
+// Verify checks the armored signature of message under namespace and
+// returns the public key that made it. The caller decides whether that key
+// is trusted.
+func Verify(armored []byte, namespace string, message io.Reader) (ssh.PublicKey, error) {
+
+	if namespace == "" {
+		return nil, ErrMissingNamespace
+	}
+
+	blob, err := dearmor(armored)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.HasPrefix(blob, []byte(magic)) {
+		return nil, ErrMalformed
+	}
+
+	var s signature
+	if err = ssh.Unmarshal(blob[len(magic):], &s); err != nil || s.Version != version {
+		return nil, ErrMalformed
+	}
+
+	if s.Namespace != namespace {
+		return nil, ErrNamespaceMismatch
+	}
+
+	pub, err := ssh.ParsePublicKey(s.PublicKey)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+
+	var sig ssh.Signature
+	if err = ssh.Unmarshal(s.Signature, &sig); err != nil {
+		return nil, ErrMalformed
+	}
+
+	data, err := signed(namespace, s.HashAlgorithm, message)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = verify(pub, data, &sig); err != nil {
+		return nil, err
+	}
+
+	return pub, nil
+
+}
+
+// signed returns the data signed for message
+func signed(namespace, hashAlgorithm string, message io.Reader) ([]byte, error) {
+
+	var h hash.Hash
+	switch hashAlgorithm {
+	case "sha512":
+		h = sha512.New()
+	case "sha256":
+		h = sha256.New()
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+
+	if _, err := io.Copy(h, message); err != nil {
+		return nil, err
+	}
+
+	return append([]byte(magic), ssh.Marshal(signedData{
+		Namespace:     namespace,
+		HashAlgorithm: hashAlgorithm,
+		Hash:          h.Sum(nil),
+	})...), nil
+
+}
+
+func sign(key crypto.Signer, data []byte) (*ssh.Signature, error) {
+
+	switch k := key.(type) {
+
+	case *rsa.PrivateKey:
+		// the vendored ssh package only signs with SHA-1, which sshsig forbids
+		digest := sha512.Sum512(data)
+		blob, err := rsa.SignPKCS1v15(nil, k, crypto.SHA512, digest[:])
+		if err != nil {
+			return nil, err
+		}
+		return &ssh.Signature{Format: "rsa-sha2-512", Blob: blob}, nil
+
+	case ed25519.PrivateKey:
+		return &ssh.Signature{Format: ssh.KeyAlgoED25519, Blob: ed25519.Sign(k, data)}, nil
+
+	case *ecdsa.PrivateKey:
+		signer, err := ssh.NewSignerFromKey(k)
+		if err != nil {
+			return nil, err
+		}
+		return signer.Sign(rand.Reader, data)
+
+	default:
+		return nil, ErrUnsupportedKey
+
+	}
+
+}
+
+func verify(pub ssh.PublicKey, data []byte, sig *ssh.Signature) error {
+
+	var h crypto.Hash
+	switch sig.Format {
+	case "rsa-sha2-512":
+		h = crypto.SHA512
+	case "rsa-sha2-256":
+		h = crypto.SHA256
+	case ssh.KeyAlgoRSA:
+		return ErrUnsupportedAlgorithm
+	default:
+		return pub.Verify(data, sig)
+	}
+
+	cryptoPub, ok := pub.(ssh.CryptoPublicKey)
+	if !ok || pub.Type() != ssh.KeyAlgoRSA {
+		return ErrUnsupportedAlgorithm
+	}
+
+	hasher := h.New()
+	hasher.Write(data)
+
+	return rsa.VerifyPKCS1v15(cryptoPub.CryptoPublicKey().(*rsa.PublicKey), h, hasher.Sum(nil), sig.Blob)
+
+}
+
+// armor returns blob between the SSH signature markers, wrapped like ssh-keygen
+func armor(blob []byte) []byte {
+
+	encoded := base64.StdEncoding.EncodeToString(blob)
+
+	buf := bytes.NewBufferString(armorBegin + "\n")
+	for len(encoded) > armorLine {
+		buf.WriteString(encoded[:armorLine] + "\n")
+		encoded = encoded[armorLine:]
+	}
+	buf.WriteString(encoded + "\n")
+	buf.WriteString(armorEnd + "\n")
+
+	return buf.Bytes()
+
+}
+
+func dearmor(armored []byte) ([]byte, error) {
+
+	text := strings.TrimSpace(string(armored))
+	if !strings.HasPrefix(text, armorBegin) || !strings.HasSuffix(text, armorEnd) {
+		return nil, ErrMalformed
+	}
+
+	text = strings.TrimSuffix(strings.TrimPrefix(text, armorBegin), armorEnd)
+	blob, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(text), ""))
+	if err != nil {
+		return nil, ErrMalformed
+	}
+
+	return blob, nil
+
+}
@@ -0,0 +1,112 @@
+package sshsig
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/ssh"
+)
+
+// made by ssh-keygen -Y sign -n file for "hello world\n"
+const (
+	fixtureKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIIS5pC/Rz/P9ZuADdQ1NWuYS6WVDMsnB7xtmmFaXG6Vk"
+
+	fixtureSignature = `-----BEGIN SSH SIGNATURE-----
+U1NIU0lHAAAAAQAAADMAAAALc3NoLWVkMjU1MTkAAAAghLmkL9HP8/1m4AN1DU1a5hLpZU
+MyycHvG2aYVpcbpWQAAAAEZmlsZQAAAAAAAAAGc2hhNTEyAAAAUwAAAAtzc2gtZWQyNTUx
+OQAAAEDpEISIwc1yVt03s8DsoIFBQj7ndr4Dw7JkTjOxZDoxH+oG480vhD0J+481K+RYWl
+6xRbefOv55aniP6So3ab0M
+-----END SSH SIGNATURE-----
+`
+)
+
+func TestVerifyOpenSSH(t *testing.T) {
+
+	pub, err := Verify([]byte(fixtureSignature), "file", strings.NewReader("hello world\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected, _, _, _, err := ssh.ParseAuthorizedKey([]byte(fixtureKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(pub.Marshal(), expected.Marshal()) {
+		t.Fatalf("signed by %s", ssh.FingerprintSHA256(pub))
+	}
+
+	if _, err = Verify([]byte(fixtureSignature), "file", strings.NewReader("hello world")); err == nil {
+		t.Fatal("verified a different message")
+	}
+
+	if _, err = Verify([]byte(fixtureSignature), "git", strings.NewReader("hello world\n")); err != ErrNamespaceMismatch {
+		t.Fatalf("expected ErrNamespaceMismatch, got %v", err)
+	}
+
+}
+
+func TestSignVerify(t *testing.T) {
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []crypto.Signer{edKey, ecKey, rsaKey} {
+
+		signature, err := Sign(key, "git", strings.NewReader("tree 4b825dc6\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pub, err := Verify(signature, "git", strings.NewReader("tree 4b825dc6\n"))
+		if err != nil {
+			t.Fatalf("%T: %v", key, err)
+		}
+
+		expected, _ := ssh.NewPublicKey(key.Public())
+		if !bytes.Equal(pub.Marshal(), expected.Marshal()) {
+			t.Fatalf("%T: signed by %s", key, ssh.FingerprintSHA256(pub))
+		}
+
+		if _, err = Verify(signature, "git", strings.NewReader("tree 4b825dc7\n")); err == nil {
+			t.Fatalf("%T: verified a different message", key)
+		}
+
+	}
+
+	if _, err = Sign(edKey, "", strings.NewReader("")); err != ErrMissingNamespace {
+		t.Fatalf("expected ErrMissingNamespace, got %v", err)
+	}
+
+}
+
+func TestVerifyMalformed(t *testing.T) {
+
+	for _, armored := range []string{
+		"",
+		"-----BEGIN SSH SIGNATURE-----\n-----END SSH SIGNATURE-----\n",
+		"-----BEGIN SSH SIGNATURE-----\nnot base64!\n-----END SSH SIGNATURE-----\n",
+		strings.Replace(fixtureSignature, "U1NIU0lH", "U1NIU0lI", 1),
+	} {
+		if _, err := Verify([]byte(armored), "file", strings.NewReader("")); err != ErrMalformed {
+			t.Fatalf("%q: expected ErrMalformed, got %v", armored, err)
+		}
+	}
+
+}
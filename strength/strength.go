@@ -0,0 +1,503 @@
+// Package strength estimates how hard a seedphrase is to guess.
+//
+// The estimate follows the approach of zxcvbn: the phrase is searched
+// for patterns an attacker would try first, such as common passwords,
+// dictionary words, keyboard walks, sequences, repeats and years, and
+// the cheapest combination of patterns and brute forced characters
+// covering the whole phrase determines its strength. Everything runs
+// locally against embedded dictionaries.
+package strength
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/cornfeedhobo/ssh-keydgen/mnemonic"
+	"github.com/cornfeedhobo/ssh-keydgen/secret"
+)
+
+// MaxLength is the number of characters estimated. Anything beyond only
+// adds guesses, so the estimate of a longer phrase is a lower bound.
+const MaxLength = 128
+
+// Match represents a pattern found in a seedphrase, covering the
+// characters from Start up to End. The matched text itself is not kept,
+// as it is part of the secret.
+type Match struct {
+	Pattern    string
+	Bits       float64
+	Start, End int
+}
+
+// Result represents the estimated strength of a seedphrase
+type Result struct {
+	// Bits is the base 2 logarithm of the estimated number of guesses
+	Bits float64
+
+	// Matches is the cheapest sequence of patterns covering the phrase
+	Matches []Match
+}
+
+var (
+	dictionaries = map[string]map[string]int{
+		"common password": ranked(strings.Fields(passwords)),
+		"dictionary word": ranked(mnemonic.Wordlist()),
+	}
+
+	// longestWord bounds the substrings looked up in the dictionaries
+	longestWord = longest(dictionaries)
+
+	// longestRow bounds the substrings looked up on the keyboards
+	longestRow = len(keyboards[len(keyboards)-1])
+
+	keyboards = []string{
+		"`1234567890-=",
+		"qwertyuiop[]\\",
+		"asdfghjkl;'",
+		"zxcvbnm,./",
+		"1qaz2wsx3edc4rfv5tgb6yhn7ujm8ik,9ol.0p;/",
+	}
+
+	leet = map[rune]rune{
+		'4': 'a', '@': 'a', '8': 'b', '(': 'c', '3': 'e', '6': 'g',
+		'1': 'i', '!': 'i', '|': 'l', '0': 'o', '$': 's', '5': 's',
+		'7': 't', '+': 't', '2': 'z',
+	}
+)
+
+func ranked(words []string) map[string]int {
+	m := make(map[string]int, len(words))
+	for i, w := range words {
+		if _, ok := m[w]; !ok {
+			m[w] = i + 1
+		}
+	}
+	return m
+}
+
+func longest(dictionaries map[string]map[string]int) (n int) {
+	for _, dictionary := range dictionaries {
+		for word := range dictionary {
+			if len(word) > n {
+				n = len(word)
+			}
+		}
+	}
+	return
+}
+
+// Estimate returns the estimated strength of the first MaxLength
+// characters of phrase. Its copies of phrase are wiped before returning.
+func Estimate(phrase []byte) *Result {
+
+	runes := bytes.Runes(phrase)
+	defer wipeRunes(runes)
+
+	if len(runes) > MaxLength {
+		return estimate(runes[:MaxLength], true)
+	}
+
+	return estimate(runes, true)
+
+}
+
+// estimate finds the cheapest cover of runes, looking for repeats only
+// when asked, as the repeated part is estimated without them
+func estimate(runes []rune, repeats bool) *Result {
+
+	var (
+		n        = len(runes)
+		perChar  = math.Log2(float64(cardinality(runes)))
+		bits     = make([]float64, n+1)
+		previous = make([]*Match, n+1)
+		byEnd    = make([][]Match, n+1)
+	)
+
+	for _, m := range findMatches(runes, repeats) {
+		byEnd[m.End] = append(byEnd[m.End], m)
+	}
+
+	for j := 1; j <= n; j++ {
+		bits[j] = bits[j-1] + perChar
+		previous[j] = &Match{Pattern: "brute force", Bits: perChar, Start: j - 1, End: j}
+		for i := range byEnd[j] {
+			m := &byEnd[j][i]
+			if b := bits[m.Start] + m.Bits; b < bits[j] {
+				bits[j] = b
+				previous[j] = m
+			}
+		}
+	}
+
+	result := &Result{Bits: bits[n]}
+	for j := n; j > 0; j = previous[j].Start {
+		result.Matches = append([]Match{*previous[j]}, result.Matches...)
+	}
+
+	return result
+
+}
+
+// GuessTime returns the average time needed to guess a phrase of the given
+// strength, when every guess costs perGuess
+func GuessTime(bits float64, perGuess time.Duration) float64 {
+	return math.Exp2(bits-1) * perGuess.Seconds()
+}
+
+// FormatSeconds returns a rough human readable form of a duration in seconds
+func FormatSeconds(seconds float64) string {
+
+	units := []struct {
+		name    string
+		seconds float64
+	}{
+		{"century", 100 * 365.25 * 24 * 3600},
+		{"year", 365.25 * 24 * 3600},
+		{"month", 365.25 / 12 * 24 * 3600},
+		{"day", 24 * 3600},
+		{"hour", 3600},
+		{"minute", 60},
+		{"second", 1},
+	}
+
+	if seconds < 1 {
+		return "less than a second"
+	}
+
+	if seconds > 1e6*units[0].seconds {
+		return "millions of centuries"
+	}
+
+	for _, u := range units {
+		if seconds >= u.seconds {
+			n := math.Floor(seconds / u.seconds)
+			name := u.name
+			if n != 1 {
+				name += "s"
+				if u.name == "century" {
+					name = "centuries"
+				}
+			}
+			return fmt.Sprintf("%.0f %s", n, name)
+		}
+	}
+
+	return ""
+
+}
+
+func findMatches(runes []rune, repeats bool) (matches []Match) {
+	matches = append(matches, dictionaryMatches(runes)...)
+	matches = append(matches, sequenceMatches(runes)...)
+	matches = append(matches, keyboardMatches(runes)...)
+	if repeats {
+		matches = append(matches, repeatMatches(runes)...)
+	}
+	matches = append(matches, yearMatches(runes)...)
+	matches = append(matches, separatorMatches(runes)...)
+	return
+}
+
+func dictionaryMatches(runes []rune) (matches []Match) {
+
+	var (
+		lower    = make([]rune, len(runes))
+		unleeted = make([]rune, len(runes))
+		buf      = make([]byte, longestWord)
+	)
+	defer wipeRunes(lower)
+	defer wipeRunes(unleeted)
+	defer secret.Wipe(buf)
+
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+		unleeted[i] = lower[i]
+		if l, ok := leet[lower[i]]; ok {
+			unleeted[i] = l
+		}
+	}
+
+	for i := range runes {
+		for j := i + 3; j <= len(runes) && j-i <= longestWord; j++ {
+			for _, candidate := range [][]rune{lower, unleeted} {
+				word, ok := encode(buf, candidate[i:j])
+				if !ok {
+					continue
+				}
+				for pattern, dictionary := range dictionaries {
+					// indexing with a converted slice does not copy it
+					rank, ok := dictionary[string(word)]
+					if !ok {
+						continue
+					}
+					bits := math.Log2(float64(rank)) + caseBits(runes[i:j])
+					if !equal(candidate[i:j], lower[i:j]) {
+						bits++
+					}
+					matches = append(matches, Match{Pattern: pattern, Bits: bits, Start: i, End: j})
+				}
+			}
+		}
+	}
+
+	return
+
+}
+
+// caseBits estimates the extra guesses needed to find the capitalization of a word
+func caseBits(runes []rune) float64 {
+
+	var upper, lower int
+	for _, r := range runes {
+		if unicode.IsUpper(r) {
+			upper++
+		} else if unicode.IsLower(r) {
+			lower++
+		}
+	}
+
+	switch {
+	case upper == 0:
+		return 0
+	case lower == 0, upper == 1 && unicode.IsUpper(runes[0]):
+		return 1
+	}
+
+	var variations float64
+	for i := 1; i <= upper && i <= lower; i++ {
+		variations += binomial(upper+lower, i)
+	}
+
+	return math.Log2(variations)
+
+}
+
+func sequenceMatches(runes []rune) (matches []Match) {
+
+	for i := 0; i+2 < len(runes); {
+		delta := runes[i+1] - runes[i]
+		j := i + 1
+		for j+1 < len(runes) && runes[j+1]-runes[j] == delta {
+			j++
+		}
+		if length := j - i + 1; length >= 3 && (delta == 1 || delta == -1) {
+			base := 26.0
+			switch {
+			case strings.ContainsRune("aAzZ019", runes[i]):
+				base = 4
+			case unicode.IsDigit(runes[i]):
+				base = 10
+			}
+			bits := math.Log2(base * float64(length))
+			if delta < 0 {
+				bits++
+			}
+			matches = append(matches, Match{Pattern: "sequence", Bits: bits, Start: i, End: j + 1})
+		}
+		i = j
+	}
+
+	return
+
+}
+
+func keyboardMatches(runes []rune) (matches []Match) {
+
+	var (
+		token    = make([]byte, longestRow)
+		reversed = make([]byte, longestRow)
+	)
+	defer secret.Wipe(token)
+	defer secret.Wipe(reversed)
+
+	for i := range runes {
+		end := i + longestRow
+		if end > len(runes) {
+			end = len(runes)
+		}
+		for j := end; j >= i+4; j-- {
+			if !lowerASCII(token, runes[i:j]) {
+				continue
+			}
+			for k := 0; k < j-i; k++ {
+				reversed[k] = token[j-i-1-k]
+			}
+			forward := containsAny(keyboards, token[:j-i])
+			if !forward && !containsAny(keyboards, reversed[:j-i]) {
+				continue
+			}
+			bits := math.Log2(float64(len(keyboards)*(j-i))) + caseBits(runes[i:j])
+			if !forward {
+				bits++
+			}
+			matches = append(matches, Match{Pattern: "keyboard walk", Bits: bits, Start: i, End: j})
+			break
+		}
+	}
+
+	return
+
+}
+
+// repeatMatches finds runs of a repeated base. Runs that would also repeat
+// from one character earlier are left to that run, and bases are estimated
+// without looking for further repeats, which keeps the search polynomial.
+func repeatMatches(runes []rune) (matches []Match) {
+
+	for i := range runes {
+		for size := 1; i+size*2 <= len(runes); size++ {
+			base := runes[i : i+size]
+			if i > 0 && runes[i-1] == runes[i+size-1] {
+				continue
+			}
+			count := 1
+			for i+(count+1)*size <= len(runes) && equal(runes[i+count*size:i+(count+1)*size], base) {
+				count++
+			}
+			if count < 2 || (size == 1 && count < 3) {
+				continue
+			}
+			bits := estimate(base, false).Bits + math.Log2(float64(count))
+			matches = append(matches, Match{Pattern: "repeat", Bits: bits, Start: i, End: i + count*size})
+		}
+	}
+
+	return
+
+}
+
+func yearMatches(runes []rune) (matches []Match) {
+
+	for i := 0; i+4 <= len(runes); i++ {
+		century := runes[i : i+2]
+		if (equal(century, []rune("19")) || equal(century, []rune("20"))) && isDigits(runes[i+2:i+4]) {
+			matches = append(matches, Match{Pattern: "year", Bits: math.Log2(200), Start: i, End: i + 4})
+		}
+	}
+
+	return
+
+}
+
+// separatorMatches finds the characters commonly used to join words, which
+// add little beyond the choice of separator
+func separatorMatches(runes []rune) (matches []Match) {
+
+	for i, r := range runes {
+		if unicode.IsSpace(r) || strings.ContainsRune("-_.,", r) {
+			matches = append(matches, Match{Pattern: "separator", Bits: 1, Start: i, End: i + 1})
+		}
+	}
+
+	return
+
+}
+
+// cardinality returns the size of the character set an attacker would brute force
+func cardinality(runes []rune) int {
+
+	var lower, upper, digit, symbol, other bool
+	for _, r := range runes {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < 0x7f:
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	var n int
+	for _, class := range []struct {
+		present bool
+		size    int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if class.present {
+			n += class.size
+		}
+	}
+
+	if n == 0 {
+		return 1
+	}
+
+	return n
+
+}
+
+func binomial(n, k int) float64 {
+	r := 1.0
+	for i := 1; i <= k; i++ {
+		r = r * float64(n-k+i) / float64(i)
+	}
+	return r
+}
+
+func containsAny(rows []string, token []byte) bool {
+	for _, row := range rows {
+		if bytes.Contains([]byte(row), token) {
+			return true
+		}
+	}
+	return false
+}
+
+func isDigits(runes []rune) bool {
+	for _, r := range runes {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func equal(a, b []rune) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// encode writes runes as UTF-8 to buf, failing if they do not fit
+func encode(buf []byte, runes []rune) ([]byte, bool) {
+	n := 0
+	for _, r := range runes {
+		if n+utf8.RuneLen(r) > len(buf) {
+			return nil, false
+		}
+		n += utf8.EncodeRune(buf[n:], r)
+	}
+	return buf[:n], true
+}
+
+// lowerASCII writes runes in lower case to buf, failing unless all are ASCII
+func lowerASCII(buf []byte, runes []rune) bool {
+	for i, r := range runes {
+		if r >= utf8.RuneSelf {
+			return false
+		}
+		buf[i] = byte(unicode.ToLower(r))
+	}
+	return true
+}
+
+func wipeRunes(r []rune) {
+	for i := range r {
+		r[i] = 0
+	}
+}
@@ -0,0 +1,93 @@
+package strength
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+func TestEstimate(t *testing.T) {
+
+	cases := []struct {
+		phrase   string
+		min, max float64
+	}{
+		{"password", 0, 2},
+		{"P@ssw0rd", 0, 6},
+		{"qwertyuiop", 0, 8},
+		{"abcdefgh", 0, 6},
+		{"aaaaaaaaaaaa", 0, 8},
+		{"monkey1987", 0, 20},
+		{"correct horse battery staple", 20, 60},
+		{"legal winner thank year wave sausage worth useful legal winner thank yellow", 120, 150},
+		{"Monkey!2019", 10, 25},
+		{"7$kQ!x9#Vb2@Lm", 80, 100},
+	}
+
+	for _, c := range cases {
+		r := Estimate([]byte(c.phrase))
+		if r.Bits < c.min || r.Bits > c.max {
+			t.Errorf("%q: expected between %.0f and %.0f bits, got %.1f (%+v)", c.phrase, c.min, c.max, r.Bits, r.Matches)
+		}
+	}
+
+	if r := Estimate(nil); r.Bits != 0 || len(r.Matches) != 0 {
+		t.Fatalf("expected empty estimate, got %+v", r)
+	}
+
+}
+
+func TestEstimateLong(t *testing.T) {
+
+	random := make([]byte, 1500)
+	if _, err := rand.Read(random); err != nil {
+		t.Fatal(err)
+	}
+
+	phrases := [][]byte{
+		bytes.Repeat([]byte("a"), 2000),
+		bytes.Repeat([]byte("ab"), 60),
+		[]byte(base64.StdEncoding.EncodeToString(random)),
+	}
+
+	start := time.Now()
+	for _, phrase := range phrases {
+		Estimate(phrase)
+	}
+
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("estimating long phrases took %s", elapsed)
+	}
+
+	// beyond MaxLength only the first characters count
+	short := Estimate(phrases[2][:MaxLength])
+	if long := Estimate(phrases[2]); long.Bits != short.Bits {
+		t.Fatalf("expected %.1f bits for the long phrase, got %.1f", short.Bits, long.Bits)
+	}
+
+}
+
+func TestGuessTime(t *testing.T) {
+
+	if s := GuessTime(11, 100*time.Millisecond); s != 102.4 {
+		t.Fatalf("expected 102.4 seconds, got %f", s)
+	}
+
+	cases := map[float64]string{
+		0.5:                    "less than a second",
+		1:                      "1 second",
+		90:                     "1 minute",
+		7200:                   "2 hours",
+		3 * 365.25 * 24 * 3600: "3 years",
+		1e300:                  "millions of centuries",
+	}
+
+	for seconds, expected := range cases {
+		if s := FormatSeconds(seconds); s != expected {
+			t.Errorf("%g: expected %q, got %q", seconds, expected, s)
+		}
+	}
+
+}
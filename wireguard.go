@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+
+	"github.com/cornfeedhobo/ssh-keydgen/keygen"
+	"github.com/cornfeedhobo/ssh-keydgen/secret"
+)
+
+// wireguardConfig writes a wg-quick [Interface] stub holding the private key
+type wireguardConfig struct {
+	filename string
+}
+
+func (c *wireguardConfig) check(keyType string) error {
+
+	if keyType != keygen.WIREGUARD {
+		return newErrorKind(kindUsage, "A WireGuard configuration needs a wireguard key, use -t wireguard")
+	}
+
+	return nil
+
+}
+
+func (c *wireguardConfig) write(k *keygen.Keydgen, filename string) (string, error) {
+
+	private, err := k.MarshalWireGuardPrivateKey()
+	if err != nil {
+		return "", newError(err.Error())
+	}
+	defer secret.Wipe(private)
+
+	public, err := k.WireGuardPublicKey()
+	if err != nil {
+		return "", newError(err.Error())
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, 256))
+	buf.WriteString("[Interface]\n")
+	buf.WriteString("# PublicKey = " + public + "\n")
+	buf.WriteString("PrivateKey = ")
+	buf.Write(private)
+	buf.WriteString("# Address = 10.0.0.1/24\n")
+	buf.WriteString("# ListenPort = 51820\n")
+	defer secret.Wipe(buf.Bytes())
+
+	if err = replaceFile(c.filename, buf.Bytes(), 0600); err != nil {
+		return "", newErrorKind(kindIO, "Error writing WireGuard configuration: "+err.Error())
+	}
+
+	return c.filename, nil
+
+}